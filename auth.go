@@ -0,0 +1,263 @@
+package gofight
+
+import (
+	"crypto/md5" //nolint:gosec
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// Locations of an API key, see SetAPIKey.
+const (
+	APIKeyHeader = "header"
+	APIKeyQuery  = "query"
+	APIKeyCookie = "cookie"
+)
+
+// SetBasicAuth sets the Authorization header for HTTP Basic authentication.
+func (rc *RequestConfig) SetBasicAuth(username, password string) *RequestConfig {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+
+	return rc.SetHeader(H{"Authorization": "Basic " + credentials})
+}
+
+// SetBearerToken sets the Authorization header to a bearer token.
+func (rc *RequestConfig) SetBearerToken(token string) *RequestConfig {
+	return rc.SetHeader(H{"Authorization": "Bearer " + token})
+}
+
+// SetAPIKey sends an API key named name in the header, the query string or
+// a cookie, as given by location: APIKeyHeader, APIKeyQuery or APIKeyCookie.
+//
+//	r.GET("/reports").SetAPIKey(gofight.APIKeyQuery, "api_key", "secret")
+func (rc *RequestConfig) SetAPIKey(location, name, value string) *RequestConfig {
+	switch location {
+	case APIKeyHeader:
+		return rc.SetHeader(H{name: value})
+	case APIKeyQuery:
+		return rc.SetQueryPairs(Pair{Key: name, Value: value})
+	case APIKeyCookie:
+		return rc.AddCookies(&http.Cookie{Name: name, Value: value})
+	default:
+		rc.addError(fmt.Errorf("SetAPIKey: unknown location %q", location))
+		return rc
+	}
+}
+
+// SetDigestAuth enables HTTP Digest authentication. When the handler
+// answers 401 with a "WWW-Authenticate: Digest" challenge, the request is
+// sent again to the same handler with the computed Authorization header.
+// MD5, SHA-256 and their -sess variants are supported, with qop=auth or no
+// qop. The response passed to the ResponseFunc is the one of the retry.
+//
+// The body must be replayable, which is the case for every body except one
+// set with SetBodyReader from a reader that is not an io.Seeker.
+func (rc *RequestConfig) SetDigestAuth(username, password string) *RequestConfig {
+	rc.digest = &digestAuth{username: username, password: password}
+
+	return rc
+}
+
+// digestAuth holds the Digest credentials of a request.
+type digestAuth struct {
+	username string
+	password string
+}
+
+// digestChallenge is a parsed Digest WWW-Authenticate challenge.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	userhash  bool
+}
+
+// answer returns a copy of req carrying credentials for the Digest
+// challenge in w, or nil when w is not a Digest challenge.
+func (d *digestAuth) answer(req *http.Request, w *httptest.ResponseRecorder) (*http.Request, error) {
+	if w.Code != http.StatusUnauthorized {
+		return nil, nil
+	}
+
+	c, ok := parseDigestChallenges(w.Result().Header.Values("WWW-Authenticate"))
+	if !ok {
+		return nil, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, errors.New("SetDigestAuth: cannot retry the request: the body is not replayable")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("SetDigestAuth: cannot retry the request: %w", err)
+		}
+		retry.Body = body
+	}
+
+	cnonce, err := newCnonce()
+	if err != nil {
+		return nil, fmt.Errorf("SetDigestAuth: %w", err)
+	}
+	retry.Header.Set("Authorization", d.authorization(c, req.Method, req.URL.RequestURI(), cnonce))
+
+	return retry, nil
+}
+
+// authorization computes the Authorization header answering c.
+func (d *digestAuth) authorization(c digestChallenge, method, uri, cnonce string) string {
+	const nc = "00000001"
+
+	newHash := digestHash(c.algorithm)
+	hashHex := func(parts ...string) string {
+		h := newHash()
+		h.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	ha1 := hashHex(d.username, c.realm, d.password)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = hashHex(ha1, c.nonce, cnonce)
+	}
+	ha2 := hashHex(method, uri)
+
+	var response string
+	if c.qop != "" {
+		response = hashHex(ha1, c.nonce, nc, cnonce, c.qop, ha2)
+	} else {
+		response = hashHex(ha1, c.nonce, ha2)
+	}
+
+	username := d.username
+	if c.userhash {
+		username = hashHex(d.username, c.realm)
+	}
+
+	params := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", c.realm),
+		fmt.Sprintf("nonce=%q", c.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("response=%q", response),
+	}
+	if c.algorithm != "" {
+		params = append(params, "algorithm="+c.algorithm)
+	}
+	if c.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%q", c.opaque))
+	}
+	if c.qop != "" {
+		params = append(params, "qop="+c.qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	if c.userhash {
+		params = append(params, "userhash=true")
+	}
+
+	return "Digest " + strings.Join(params, ", ")
+}
+
+// digestHash returns the hash function of a Digest algorithm.
+func digestHash(algorithm string) func() hash.Hash {
+	if strings.HasPrefix(strings.ToUpper(algorithm), "SHA-256") {
+		return sha256.New
+	}
+
+	return md5.New
+}
+
+// parseDigestChallenges returns the first supported Digest challenge.
+func parseDigestChallenges(headers []string) (digestChallenge, bool) {
+	for _, header := range headers {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+
+		params := parseAuthParams(rest)
+		c := digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			userhash:  strings.EqualFold(params["userhash"], "true"),
+		}
+		switch strings.ToUpper(c.algorithm) {
+		case "", "MD5", "MD5-SESS", "SHA-256", "SHA-256-SESS":
+		default:
+			continue
+		}
+		if qop, ok := params["qop"]; ok {
+			found := false
+			for option := range strings.SplitSeq(qop, ",") {
+				if strings.TrimSpace(option) == "auth" {
+					found = true
+				}
+			}
+			if !found {
+				continue
+			}
+			c.qop = "auth"
+		}
+
+		return c, true
+	}
+
+	return digestChallenge{}, false
+}
+
+// parseAuthParams parses comma separated key=value and key="value" pairs.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for s = strings.TrimSpace(s); s != ""; {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimSpace(rest)
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			var buf strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				buf.WriteByte(rest[i])
+			}
+			value = buf.String()
+			rest = rest[min(i+1, len(rest)):]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+			rest = "," + rest
+		}
+		params[key] = value
+
+		_, s, _ = strings.Cut(rest, ",")
+		s = strings.TrimSpace(s)
+	}
+
+	return params
+}
+
+// newCnonce returns a random client nonce.
+func newCnonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,124 @@
+package gofight
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSetBasicAuthAndBearerToken tests Authorization header helpers
+func TestSetBasicAuthAndBearerToken(t *testing.T) {
+	New().GET("/").
+		SetBasicAuth("alice", "secret").
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			user, pass, ok := rq.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, "alice", user)
+			assert.Equal(t, "secret", pass)
+		})
+
+	New().GET("/").
+		SetBearerToken("token").
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "Bearer token", rq.Header.Get("Authorization"))
+		})
+}
+
+// TestSetAPIKey tests API keys sent in headers, query strings and cookies
+func TestSetAPIKey(t *testing.T) {
+	New().GET("/?a=1").
+		SetAPIKey(APIKeyHeader, "X-API-Key", "h").
+		SetAPIKey(APIKeyQuery, "api_key", "q").
+		SetAPIKey(APIKeyCookie, "key", "c").
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "h", rq.Header.Get("X-API-Key"))
+			assert.Equal(t, "a=1&api_key=q", rq.URL.RawQuery)
+			cookie, err := rq.Cookie("key")
+			require.NoError(t, err)
+			assert.Equal(t, "c", cookie.Value)
+		})
+
+	assert.ErrorContains(t, New().SetAPIKey("body", "k", "v").Err(), `unknown location "body"`)
+}
+
+// TestDigestAuthorization tests the RFC 7616 section 3.9.1 example
+func TestDigestAuthorization(t *testing.T) {
+	d := &digestAuth{username: "Mufasa", password: "Circle of Life"}
+	c := digestChallenge{
+		realm:  "http-auth@example.org",
+		nonce:  "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+		opaque: "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+		qop:    "auth",
+	}
+	cnonce := "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+
+	c.algorithm = "MD5"
+	assert.Contains(t, d.authorization(c, "GET", "/dir/index.html", cnonce),
+		`response="8ca523f5e9506fed4657c9700eebdbec"`)
+
+	c.algorithm = "SHA-256"
+	assert.Contains(t, d.authorization(c, "GET", "/dir/index.html", cnonce),
+		`response="753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"`)
+}
+
+// digestHandler challenges requests without Digest credentials
+func digestHandler(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Digest ") {
+		w.Header().Add("WWW-Authenticate", `Basic realm="api"`)
+		w.Header().Add("WWW-Authenticate", `Digest realm="api", qop="auth-int, auth", algorithm=SHA-256, nonce="abc", opaque="xyz"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	params := parseAuthParams(strings.TrimPrefix(auth, "Digest "))
+	d := &digestAuth{username: "alice", password: "secret"}
+	c := digestChallenge{realm: "api", nonce: "abc", opaque: "xyz", algorithm: "SHA-256", qop: "auth"}
+	want := parseAuthParams(strings.TrimPrefix(d.authorization(c, r.Method, r.URL.RequestURI(), params["cnonce"]), "Digest "))
+	if params["response"] != want["response"] || params["opaque"] != "xyz" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	_, _ = io.WriteString(w, "welcome "+params["username"]+" "+string(body))
+}
+
+// TestSetDigestAuth tests answering a Digest challenge
+func TestSetDigestAuth(t *testing.T) {
+	New().POST("/private?x=1").
+		SetBody("payload").
+		SetDigestAuth("alice", "secret").
+		Run(http.HandlerFunc(digestHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+			assert.Equal(t, "welcome alice payload", r.Body.String())
+			assert.Contains(t, rq.Header.Get("Authorization"), `uri="/private?x=1"`)
+		})
+
+	New().GET("/private").
+		SetDigestAuth("alice", "wrong").
+		Run(http.HandlerFunc(digestHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusForbidden, r.Code)
+		})
+
+	err := New().POST("/private").
+		SetBodyReader(io.NopCloser(strings.NewReader("once"))).
+		SetDigestAuth("alice", "secret").
+		RunE(http.HandlerFunc(digestHandler), func(r HTTPResponse, rq HTTPRequest) {
+			t.Fatal("response func should not run")
+		})
+	assert.ErrorContains(t, err, "not replayable")
+}
+
+// TestParseAuthParams tests parsing quoted and token parameters
+func TestParseAuthParams(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"realm":     `a "b", c`,
+		"qop":       "auth",
+		"algorithm": "MD5",
+	}, parseAuthParams(`realm="a \"b\", c", qop=auth,algorithm=MD5`))
+}
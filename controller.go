@@ -0,0 +1,173 @@
+package gofight
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SetReadDeadline records the deadline. The request body is read from
+// memory, so the deadline is not enforced.
+func (r *Recorder) SetReadDeadline(deadline time.Time) error {
+	return r.recordDeadline(CallSetReadDeadline, deadline)
+}
+
+// SetWriteDeadline records the deadline. Writes go to memory, so the
+// deadline is not enforced.
+func (r *Recorder) SetWriteDeadline(deadline time.Time) error {
+	return r.recordDeadline(CallSetWriteDeadline, deadline)
+}
+
+// recordDeadline records a deadline call.
+func (r *Recorder) recordDeadline(kind CallKind, deadline time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hijacked != nil {
+		return http.ErrHijacked
+	}
+	r.record(Call{Kind: kind, Deadline: deadline})
+
+	return nil
+}
+
+// EnableFullDuplex records the call. The request body can always be read
+// while writing the response to a Recorder.
+func (r *Recorder) EnableFullDuplex() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hijacked != nil {
+		return http.ErrHijacked
+	}
+	r.record(Call{Kind: CallEnableFullDuplex})
+
+	return nil
+}
+
+// Hijack implements http.Hijacker with an in-memory connection. Reading it
+// returns the rest of the request body, then io.EOF as if the client sent
+// nothing more. The bytes written to it are kept, see HijackedBytes.
+//
+// Once hijacked, Write and Flush fail with http.ErrHijacked and
+// WriteHeader is superfluous, so Code and Body only hold the response
+// written before the hijack.
+func (r *Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hijacked != nil {
+		return nil, nil, http.ErrHijacked
+	}
+	r.record(Call{Kind: CallHijack})
+
+	input := r.input
+	if input == nil {
+		input = http.NoBody
+	}
+	r.hijacked = &hijackedConn{rec: r, input: input}
+
+	return r.hijacked, bufio.NewReadWriter(bufio.NewReader(r.hijacked), bufio.NewWriter(r.hijacked)), nil
+}
+
+// Hijacked reports whether the handler hijacked the connection.
+func (r *Recorder) Hijacked() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.hijacked != nil
+}
+
+// HijackedBytes returns the raw bytes written to the hijacked connection
+// so far.
+func (r *Recorder) HijackedBytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]byte(nil), r.raw...)
+}
+
+// HijackedConnClosed reports whether the handler closed the hijacked
+// connection.
+func (r *Recorder) HijackedConnClosed() bool {
+	r.mu.Lock()
+	conn := r.hijacked
+	r.mu.Unlock()
+
+	return conn != nil && conn.isClosed()
+}
+
+// hijackedConn is the connection returned by Recorder.Hijack.
+type hijackedConn struct {
+	rec   *Recorder
+	input io.Reader
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Read reads the rest of the request body.
+func (c *hijackedConn) Read(b []byte) (int, error) {
+	if c.isClosed() {
+		return 0, net.ErrClosed
+	}
+
+	return c.input.Read(b)
+}
+
+// Write keeps b in the Recorder.
+func (c *hijackedConn) Write(b []byte) (int, error) {
+	if c.isClosed() {
+		return 0, net.ErrClosed
+	}
+
+	c.rec.mu.Lock()
+	c.rec.raw = append(c.rec.raw, b...)
+	c.rec.mu.Unlock()
+
+	return len(b), nil
+}
+
+// Close closes the connection.
+func (c *hijackedConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.closed = true
+
+	return nil
+}
+
+// isClosed reports whether Close was called.
+func (c *hijackedConn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+// LocalAddr returns the address of the server side.
+func (c *hijackedConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 80}
+}
+
+// RemoteAddr returns the client address used by Transport and
+// httptest.NewRequest.
+func (c *hijackedConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1234}
+}
+
+// SetDeadline is a no-op: the connection never blocks.
+func (c *hijackedConn) SetDeadline(time.Time) error { return nil }
+
+// SetReadDeadline is a no-op: the connection never blocks.
+func (c *hijackedConn) SetReadDeadline(time.Time) error { return nil }
+
+// SetWriteDeadline is a no-op: the connection never blocks.
+func (c *hijackedConn) SetWriteDeadline(time.Time) error { return nil }
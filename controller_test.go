@@ -0,0 +1,106 @@
+package gofight
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wrappedWriter is a middleware ResponseWriter exposing the writer it wraps
+type wrappedWriter struct {
+	http.ResponseWriter
+}
+
+func (w *wrappedWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// TestResponseController tests the deadlines and full duplex calls
+func TestResponseController(t *testing.T) {
+	readDeadline := time.Now().Add(time.Second)
+	writeDeadline := time.Now().Add(2 * time.Second)
+
+	var errs []error
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(&wrappedWriter{w})
+		errs = append(errs,
+			rc.SetReadDeadline(readDeadline),
+			rc.SetWriteDeadline(writeDeadline),
+			rc.EnableFullDuplex(),
+		)
+
+		_, _ = io.WriteString(w, "got ")
+		errs = append(errs, rc.Flush())
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	})
+
+	NewT(t).POST("/upload").SetBody("data").Run(handler, func(r HTTPResponse, rq HTTPRequest) {
+		assert.Equal(t, []error{nil, nil, nil, nil}, errs)
+		assert.Equal(t, "got data", r.Body.String())
+		r.Expect().Chunks("got ", "data")
+
+		require.Len(t, r.Calls(CallSetReadDeadline), 1)
+		assert.True(t, readDeadline.Equal(r.Calls(CallSetReadDeadline)[0].Deadline))
+		require.Len(t, r.Calls(CallSetWriteDeadline), 1)
+		assert.True(t, writeDeadline.Equal(r.Calls(CallSetWriteDeadline)[0].Deadline))
+		assert.Len(t, r.Calls(CallEnableFullDuplex), 1)
+		assert.Len(t, r.Calls(CallFlush), 1)
+		assert.False(t, r.Hijacked())
+		assert.Equal(t, "EnableFullDuplex", r.Timeline()[2].Kind.String())
+	})
+}
+
+// TestRecorderHijack tests a handler taking over the connection
+func TestRecorderHijack(t *testing.T) {
+	var (
+		errs       []error
+		remoteAddr net.Addr
+		received   string
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Before", "1")
+		conn, brw, err := http.NewResponseController(&wrappedWriter{w}).Hijack()
+		require.NoError(t, err)
+		remoteAddr = conn.RemoteAddr()
+
+		b, _ := io.ReadAll(brw)
+		received = string(b)
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: raw\r\n\r\n")
+		_ = brw.Flush()
+		_, _ = conn.Write([]byte("pong"))
+		require.NoError(t, conn.Close())
+
+		_, err = w.Write([]byte("late"))
+		errs = append(errs, err, http.NewResponseController(w).Flush(), http.NewResponseController(w).SetWriteDeadline(time.Time{}))
+		_, _, err = w.(http.Hijacker).Hijack()
+		errs = append(errs, err)
+		w.WriteHeader(http.StatusOK)
+
+		_, err = conn.Write([]byte("closed"))
+		errs = append(errs, err)
+	})
+
+	NewT(t).POST("/raw").SetBody("ping").Run(handler, func(r HTTPResponse, rq HTTPRequest) {
+		assert.Equal(t, "ping", received)
+		assert.Equal(t, "192.0.2.1:1234", remoteAddr.String())
+		assert.True(t, r.Hijacked())
+		assert.True(t, r.HijackedConnClosed())
+		assert.Equal(t, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: raw\r\n\r\npong", string(r.HijackedBytes()))
+
+		require.Len(t, errs, 5)
+		for _, err := range errs[:4] {
+			assert.ErrorIs(t, err, http.ErrHijacked)
+		}
+		assert.True(t, errors.Is(errs[4], net.ErrClosed))
+
+		assert.Len(t, r.Calls(CallHijack), 1)
+		assert.Len(t, r.Superfluous(), 1)
+		assert.Empty(t, r.Calls(CallWrite))
+		assert.Equal(t, 0, r.Body.Len())
+	})
+}
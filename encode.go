@@ -0,0 +1,469 @@
+package gofight
+
+import (
+	"encoding"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pair is a single key/value used to build query strings and form bodies
+// in a fixed order, with repeated keys allowed. Value accepts the same types
+// as the values of SetQueryD.
+type Pair struct {
+	Key   string
+	Value any
+}
+
+// field is an encoded key/value.
+type field struct {
+	key   string
+	value string
+}
+
+// encodeFields renders fields as an application/x-www-form-urlencoded
+// string, keeping their order.
+func encodeFields(fields []field) string {
+	var buf strings.Builder
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte('&')
+		}
+		buf.WriteString(url.QueryEscape(f.key))
+		buf.WriteByte('=')
+		buf.WriteString(url.QueryEscape(f.value))
+	}
+
+	return buf.String()
+}
+
+// encodeD flattens data with keys in sorted order, see encodeValue.
+func encodeD(data D) ([]field, error) {
+	var fields []field
+	for _, k := range slices.Sorted(maps.Keys(data)) {
+		f, err := encodeValue(k, data[k], true)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f...)
+	}
+
+	return fields, nil
+}
+
+// encodePairs flattens pairs in order, see encodeValue.
+func encodePairs(pairs []Pair) ([]field, error) {
+	var fields []field
+	for _, p := range pairs {
+		f, err := encodeValue(p.Key, p.Value, true)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f...)
+	}
+
+	return fields, nil
+}
+
+// encodeValues flattens v keeping the order of the values of each key.
+func encodeValues(v url.Values) []field {
+	var fields []field
+	for _, k := range slices.Sorted(maps.Keys(v)) {
+		for _, value := range v[k] {
+			fields = append(fields, field{key: k, value: value})
+		}
+	}
+
+	return fields
+}
+
+// encodeValue flattens v under key using the Rails/PHP bracket convention:
+//
+//   - scalars are formatted: numbers and bools with strconv, time.Time as
+//     RFC 3339, and encoding.TextMarshaler and fmt.Stringer values as text;
+//   - maps become key[sub]=..., with sub-keys in sorted order;
+//   - slices of scalars repeat the key, as key=1&key=2 at the top level so
+//     that the key is used verbatim, and as key[sub][]=1 when nested;
+//   - slices of maps or slices are indexed, as key[0][sub]=....
+//
+// Nil values are skipped.
+func encodeValue(key string, v any, top bool) ([]field, error) {
+	if s, ok, err := formatScalar(v); ok || err != nil {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		return []field{{key: key, value: s}}, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, nil
+	}
+
+	if s, ok, err := formatScalar(rv.Interface()); ok || err != nil {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		return []field{{key: key, value: s}}, nil
+	}
+
+	var fields []field
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%s: unsupported map key type %s", key, rv.Type().Key())
+		}
+		keys := rv.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		for _, k := range keys {
+			f, err := encodeValue(key+"["+k.String()+"]", rv.MapIndex(k).Interface(), false)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f...)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range rv.Len() {
+			elem := rv.Index(i).Interface()
+			elemKey := key
+			switch {
+			case isComposite(elem):
+				elemKey = key + "[" + strconv.Itoa(i) + "]"
+			case !top:
+				elemKey = key + "[]"
+			}
+			f, err := encodeValue(elemKey, elem, false)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f...)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported type %s", key, rv.Type())
+	}
+
+	return fields, nil
+}
+
+// isComposite reports whether v encodes as nested keys.
+func isComposite(v any) bool {
+	if _, ok, _ := formatScalar(v); ok {
+		return false
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	default:
+		return false
+	}
+}
+
+// formatScalar formats v when it is a scalar value. A nil pointer is not a
+// scalar: calling its String or MarshalText method could panic.
+func formatScalar(v any) (string, bool, error) {
+	if rv := reflect.ValueOf(v); !rv.IsValid() || rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return "", false, nil
+	}
+
+	switch v := v.(type) {
+	case string:
+		return v, true, nil
+	case []byte:
+		return string(v), true, nil
+	case bool:
+		return strconv.FormatBool(v), true, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true, nil
+	case encoding.TextMarshaler:
+		b, err := v.MarshalText()
+		return string(b), true, err
+	case fmt.Stringer:
+		return v.String(), true, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), true, nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32), true, nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), true, nil
+	default:
+		return "", false, nil
+	}
+}
+
+// appendQuery appends an encoded query string to the request path.
+func (rc *RequestConfig) appendQuery(query string) {
+	if query == "" {
+		return
+	}
+
+	if strings.Contains(rc.Path, "?") {
+		rc.Path = rc.Path + "&" + query
+	} else {
+		rc.Path = rc.Path + "?" + query
+	}
+}
+
+// SetQueryValues appends url.Values to the query string, so keys may
+// repeat. Keys are sorted and the values of a key keep their order.
+func (rc *RequestConfig) SetQueryValues(query url.Values) *RequestConfig {
+	rc.appendQuery(encodeFields(encodeValues(query)))
+
+	return rc
+}
+
+// SetQueryPairs appends the pairs to the query string in the given order.
+//
+//	r.GET("/search").SetQueryPairs(
+//	  gofight.Pair{Key: "sort", Value: "name"},
+//	  gofight.Pair{Key: "sort", Value: "-date"},
+//	  gofight.Pair{Key: "page", Value: 2},
+//	)
+func (rc *RequestConfig) SetQueryPairs(pairs ...Pair) *RequestConfig {
+	fields, err := encodePairs(pairs)
+	if err != nil {
+		rc.addError(fmt.Errorf("SetQueryPairs: %w", err))
+		return rc
+	}
+
+	rc.appendQuery(encodeFields(fields))
+
+	return rc
+}
+
+// SetFormValues sets a form body from url.Values, so keys may repeat.
+func (rc *RequestConfig) SetFormValues(body url.Values) *RequestConfig {
+	rc.setBody(encodeFields(encodeValues(body)))
+
+	return rc
+}
+
+// SetFormPairs sets a form body from pairs, in the given order.
+func (rc *RequestConfig) SetFormPairs(pairs ...Pair) *RequestConfig {
+	fields, err := encodePairs(pairs)
+	if err != nil {
+		rc.addError(fmt.Errorf("SetFormPairs: %w", err))
+		return rc
+	}
+
+	rc.setBody(encodeFields(fields))
+
+	return rc
+}
+
+// SetFormD sets a form body from structured data, encoded like SetQueryD:
+//
+//	SetFormD(gofight.D{"filter": gofight.D{"status": []string{"open"}}})
+//	// filter[status][]=open
+func (rc *RequestConfig) SetFormD(body D) *RequestConfig {
+	fields, err := encodeD(body)
+	if err != nil {
+		rc.addError(fmt.Errorf("SetFormD: %w", err))
+		return rc
+	}
+
+	rc.setBody(encodeFields(fields))
+
+	return rc
+}
+
+// encodeStruct flattens the exported fields of the struct v using the field
+// tag named tag, e.g. `query:"page,omitempty"`. A field without a tag uses
+// its Go name, "-" skips it, and omitempty skips zero values. Nil pointers
+// are skipped with or without omitempty. Embedded
+// structs are flattened, nested structs use brackets, and other values are
+// encoded like SetQueryD values.
+func encodeStruct(tag string, v any) ([]field, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct, got %T", v)
+	}
+
+	return encodeStructFields(tag, "", rv)
+}
+
+// encodeStructFields flattens the struct rv under prefix.
+func encodeStructFields(tag, prefix string, rv reflect.Value) ([]field, error) {
+	var fields []field
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		sf := rt.Field(i)
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+
+		name, opts, tagged := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if sf.Anonymous && name == "" && !tagged {
+			for fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if _, scalar := scalarValue(fv); fv.Kind() == reflect.Struct && !scalar {
+				f, err := encodeStructFields(tag, prefix, fv)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, f...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		if slices.Contains(strings.Split(opts, ","), "omitempty") && fv.IsZero() {
+			continue
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + "[" + name + "]"
+		}
+
+		inner := fv
+		for inner.Kind() == reflect.Pointer && !inner.IsNil() {
+			inner = inner.Elem()
+		}
+		if inner.Kind() == reflect.Pointer {
+			continue
+		}
+		value, scalar := scalarValue(inner)
+		if inner.Kind() == reflect.Struct && !scalar {
+			f, err := encodeStructFields(tag, key, inner)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f...)
+			continue
+		}
+		if !scalar {
+			value = fv.Interface()
+		}
+
+		f, err := encodeValue(key, value, prefix == "")
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f...)
+	}
+
+	return fields, nil
+}
+
+// scalarValue returns the value rv is formatted from when it is a single
+// value, such as a time.Time or an encoding.TextMarshaler. A type whose
+// MarshalText has a pointer receiver is returned as a pointer, to rv itself
+// when it is addressable or to a copy otherwise.
+func scalarValue(rv reflect.Value) (any, bool) {
+	if !rv.CanInterface() {
+		return nil, false
+	}
+
+	v := rv.Interface()
+	if _, ok := v.(encoding.TextMarshaler); !ok && reflect.PointerTo(rv.Type()).Implements(textMarshalerType) {
+		if rv.CanAddr() {
+			return rv.Addr().Interface(), true
+		}
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		return ptr.Interface(), true
+	}
+	if _, ok, _ := formatScalar(v); ok {
+		return v, true
+	}
+
+	return nil, false
+}
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// SetQueryStruct appends the fields of a struct to the query string, named
+// by their `query` tags, so tests can reuse the types handlers bind into.
+//
+//	type ListParams struct {
+//	  Page   int      `query:"page"`
+//	  Status []string `query:"status,omitempty"`
+//	}
+//
+//	r.GET("/issues").SetQueryStruct(ListParams{Page: 2})
+func (rc *RequestConfig) SetQueryStruct(v any) *RequestConfig {
+	fields, err := encodeStruct("query", v)
+	if err != nil {
+		rc.addError(fmt.Errorf("SetQueryStruct: %w", err))
+		return rc
+	}
+
+	rc.appendQuery(encodeFields(fields))
+
+	return rc
+}
+
+// SetFormStruct sets a form body from the fields of a struct, named by
+// their `form` tags.
+func (rc *RequestConfig) SetFormStruct(v any) *RequestConfig {
+	fields, err := encodeStruct("form", v)
+	if err != nil {
+		rc.addError(fmt.Errorf("SetFormStruct: %w", err))
+		return rc
+	}
+
+	rc.setBody(encodeFields(fields))
+
+	return rc
+}
+
+// SetHeaderStruct sets headers from the fields of a struct, named by their
+// `header` tags. A slice sends one header line per value.
+func (rc *RequestConfig) SetHeaderStruct(v any) *RequestConfig {
+	fields, err := encodeStruct("header", v)
+	if err != nil {
+		rc.addError(fmt.Errorf("SetHeaderStruct: %w", err))
+		return rc
+	}
+
+	headers := make(http.Header, len(fields))
+	for _, f := range fields {
+		headers.Add(f.key, f.value)
+	}
+
+	return rc.SetHeaders(headers)
+}
@@ -0,0 +1,231 @@
+package gofight
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rawBodyHandler writes the raw query string and request body
+func rawBodyHandler(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	_, _ = io.WriteString(w, r.URL.RawQuery+"|"+string(body))
+}
+
+// TestSetQueryDEncoding tests formatting and bracket nesting in SetQueryD
+func TestSetQueryDEncoding(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	New().GET("/query?a=1").
+		SetQueryD(D{
+			"page":   2,
+			"ratio":  0.5,
+			"active": true,
+			"since":  ts,
+			"ip":     net.ParseIP("127.0.0.1"),
+			"skip":   nil,
+			"filter": D{"status": []string{"open", "closed"}, "owner": H{"id": "7"}},
+			"items":  []D{{"sku": "a"}, {"sku": "b"}},
+		}).
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "a=1&active=true"+
+				"&filter%5Bowner%5D%5Bid%5D=7"+
+				"&filter%5Bstatus%5D%5B%5D=open&filter%5Bstatus%5D%5B%5D=closed"+
+				"&ip=127.0.0.1"+
+				"&items%5B0%5D%5Bsku%5D=a&items%5B1%5D%5Bsku%5D=b"+
+				"&page=2&ratio=0.5&since=2024-05-01T12%3A00%3A00Z|", r.Body.String())
+
+			q := rq.URL.Query()
+			assert.Equal(t, []string{"open", "closed"}, q["filter[status][]"])
+			assert.Equal(t, "7", q.Get("filter[owner][id]"))
+		})
+
+	New().GET("/query").
+		SetQueryD(D{
+			"since": (*time.Time)(nil),
+			"ip":    (*net.IP)(nil),
+			"dates": []*time.Time{nil, &ts},
+		}).
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "dates=2024-05-01T12%3A00%3A00Z|", r.Body.String())
+		})
+
+	r := New().GET("/query").SetQueryD(D{"bad": D{"fn": func() {}}})
+	assert.ErrorContains(t, r.Err(), "SetQueryD: bad[fn]: unsupported type func()")
+}
+
+// TestSetQueryValuesAndPairs tests repeated keys and ordered pairs
+func TestSetQueryValuesAndPairs(t *testing.T) {
+	New().GET("/query").
+		SetQueryValues(url.Values{"tag": {"b", "a"}, "id": {"1"}}).
+		SetQueryPairs(Pair{Key: "sort", Value: "name"}, Pair{Key: "sort", Value: "-date"}, Pair{Key: "page", Value: 3}).
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "id=1&tag=b&tag=a&sort=name&sort=-date&page=3|", r.Body.String())
+		})
+}
+
+// TestSetFormEncoding tests url.Values, ordered and nested form bodies
+func TestSetFormEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		rc   *RequestConfig
+		want string
+	}{
+		{
+			name: "values",
+			rc:   New().POST("/form").SetFormValues(url.Values{"a": {"1", "2"}}),
+			want: "a=1&a=2",
+		},
+		{
+			name: "pairs",
+			rc:   New().POST("/form").SetFormPairs(Pair{Key: "z", Value: 1}, Pair{Key: "a", Value: false}),
+			want: "z=1&a=false",
+		},
+		{
+			name: "nested",
+			rc:   New().POST("/form").SetFormD(D{"filter": D{"status": []string{"open"}}}),
+			want: "filter%5Bstatus%5D%5B%5D=open",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rc.Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+				assert.Equal(t, "|"+tt.want, r.Body.String())
+				assert.Equal(t, ApplicationForm, rq.Header.Get(ContentType))
+			})
+		})
+	}
+
+	assert.Error(t, New().POST("/form").SetFormPairs(Pair{Key: "c", Value: make(chan int)}).Err())
+}
+
+type pagination struct {
+	Page  int `query:"page" form:"page"`
+	Limit int `query:"limit,omitempty" form:"limit,omitempty"`
+}
+
+type listParams struct {
+	pagination
+	Status  []string   `query:"status" form:"status[]"`
+	Since   time.Time  `query:"since,omitempty" form:"since,omitempty"`
+	IP      net.IP     `query:"ip,omitempty" form:"ip,omitempty"`
+	Owner   *ownerRef  `query:"owner,omitempty" form:"owner,omitempty"`
+	Ignored string     `query:"-" form:"-"`
+	Raw     string     `form:"raw"`
+	Level   *int       `query:"level"`
+	Until   *time.Time `query:"until" form:"until"`
+}
+
+type ownerRef struct {
+	ID string `query:"id" form:"id"`
+}
+
+// TestSetQueryStruct tests query strings built from tagged structs
+func TestSetQueryStruct(t *testing.T) {
+	New().GET("/issues").
+		SetQueryStruct(listParams{pagination: pagination{Page: 2}, Status: []string{"open"}}).
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "page=2&status=open&Raw=|", r.Body.String())
+		})
+
+	New().GET("/issues").
+		SetQueryStruct(&listParams{
+			Status: []string{"open", "closed"},
+			Since:  time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			IP:     net.ParseIP("10.0.0.1"),
+			Owner:  &ownerRef{ID: "7"},
+			Raw:    "x",
+		}).
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			q := rq.URL.Query()
+			assert.Equal(t, []string{"open", "closed"}, q["status"])
+			assert.Equal(t, "2024-05-01T00:00:00Z", q.Get("since"))
+			assert.Equal(t, "10.0.0.1", q.Get("ip"))
+			assert.Equal(t, "7", q.Get("owner[id]"))
+			assert.Equal(t, "x", q.Get("Raw"))
+			assert.False(t, q.Has("Ignored"))
+			assert.False(t, q.Has("level"))
+			assert.False(t, q.Has("until"))
+		})
+
+	until := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	New().GET("/issues").
+		SetQueryStruct(struct {
+			Since *time.Time `query:"since"`
+			Until *time.Time `query:"until"`
+		}{Until: &until}).
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "until=2024-06-01T00%3A00%3A00Z|", r.Body.String())
+		})
+
+	assert.ErrorContains(t, New().SetQueryStruct("nope").Err(), "SetQueryStruct: expected a struct")
+}
+
+// sortOrder marshals as text through a pointer receiver only
+type sortOrder struct {
+	Field string
+	Desc  bool
+}
+
+func (o *sortOrder) MarshalText() ([]byte, error) {
+	if o.Desc {
+		return []byte("-" + o.Field), nil
+	}
+	return []byte(o.Field), nil
+}
+
+type sortParams struct {
+	Sort sortOrder  `query:"sort"`
+	Then *sortOrder `query:"then"`
+}
+
+// TestSetQueryStructPointerTextMarshaler tests fields whose MarshalText has
+// a pointer receiver, in addressable and non-addressable structs
+func TestSetQueryStructPointerTextMarshaler(t *testing.T) {
+	params := sortParams{Sort: sortOrder{Field: "date", Desc: true}, Then: &sortOrder{Field: "name"}}
+
+	for name, v := range map[string]any{"value": params, "pointer": &params} {
+		t.Run(name, func(t *testing.T) {
+			NewT(t).GET("/issues").SetQueryStruct(v).
+				Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+					assert.Equal(t, "sort=-date&then=name|", r.Body.String())
+				})
+		})
+	}
+}
+
+// TestSetFormStruct tests form bodies built from tagged structs
+func TestSetFormStruct(t *testing.T) {
+	level := 3
+	New().POST("/issues").
+		SetFormStruct(listParams{Status: []string{"open"}, Raw: "a b", Level: &level}).
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "|page=0&status%5B%5D=open&raw=a+b&Level=3", r.Body.String())
+			assert.Equal(t, ApplicationForm, rq.Header.Get(ContentType))
+		})
+}
+
+// TestSetHeaderStruct tests headers built from tagged structs
+func TestSetHeaderStruct(t *testing.T) {
+	type headers struct {
+		Version   string   `header:"x-version"`
+		Accept    []string `header:"Accept"`
+		RequestID string   `header:"X-Request-Id,omitempty"`
+	}
+
+	New().GET("/").
+		SetHeader(H{"x-version": "1", "X-Team": "core"}).
+		SetHeaderStruct(headers{Version: "2", Accept: []string{"text/html", "application/json"}}).
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "2", rq.Header.Get("X-Version"))
+			assert.Equal(t, "core", rq.Header.Get("X-Team"))
+			assert.Equal(t, []string{"text/html", "application/json"}, rq.Header.Values("Accept"))
+			assert.Empty(t, rq.Header.Values("X-Request-Id"))
+		})
+}
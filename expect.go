@@ -0,0 +1,377 @@
+package gofight
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Expectation collects mismatches between a recorded response and what the
+// test expects. Every check is evaluated and every failure is kept, so a
+// single run reports all of them together instead of stopping at the first.
+//
+//	r.POST("/users").
+//	  SetJSON(gofight.D{"name": "foo"}).
+//	  Run(engine, func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+//	    r.Expect().
+//	      Status(http.StatusCreated).
+//	      Header("Location", "/users/1").
+//	      ContentType("application/json").
+//	      BodyContains(`"name":"foo"`)
+//	  })
+//
+// Expectations created inside a ResponseFunc are checked once the function
+// returns: RunE returns their failures, and Run reports them with t.Errorf
+// for a client created with NewT, or panics with them otherwise. They are
+// not checked when the function panics.
+type Expectation struct {
+	resp     HTTPResponse
+	failures []string
+}
+
+// expectations tracks the Expectation values created for one response.
+type expectations struct {
+	list []*Expectation
+}
+
+// Expect starts a chain of expectations on the response.
+func (r HTTPResponse) Expect() *Expectation {
+	e := &Expectation{resp: r}
+	if r.expect != nil {
+		r.expect.list = append(r.expect.list, e)
+	}
+
+	return e
+}
+
+// err aggregates the failures of every expectation created for the response.
+func (e *expectations) err() error {
+	var failures []string
+	for _, exp := range e.list {
+		failures = append(failures, exp.failures...)
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d expectation(s) failed:\n\t%s",
+		len(failures), strings.Join(failures, "\n\t"))
+}
+
+// failf reports a failed assertion of a connection or stream to t. Without
+// a test there is no Run to return the failure, so it panics.
+func failf(t testing.TB, format string, args ...any) {
+	msg := "gofight: " + fmt.Sprintf(format, args...)
+	if t == nil {
+		panic(msg)
+	}
+
+	t.Helper()
+	t.Errorf("%s", msg)
+}
+
+// Err returns the failures recorded by this chain, or nil if every
+// expectation was met.
+func (e *Expectation) Err() error {
+	if len(e.failures) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(e.failures, "; "))
+}
+
+// fail records a mismatch.
+func (e *Expectation) fail(format string, args ...any) *Expectation {
+	e.failures = append(e.failures, fmt.Sprintf(format, args...))
+
+	return e
+}
+
+// Status expects the response status code.
+func (e *Expectation) Status(code int) *Expectation {
+	if e.resp.Code != code {
+		return e.fail("expected status %d %s, got %d %s",
+			code, http.StatusText(code), e.resp.Code, http.StatusText(e.resp.Code))
+	}
+
+	return e
+}
+
+// Header expects the first value of the response header key to equal value.
+func (e *Expectation) Header(key, value string) *Expectation {
+	if _, ok := e.resp.Header()[http.CanonicalHeaderKey(key)]; !ok {
+		return e.fail("expected header %q to be %q, but it is missing", key, value)
+	}
+
+	if got := e.resp.Header().Get(key); got != value {
+		return e.fail("expected header %q to be %q, got %q", key, value, got)
+	}
+
+	return e
+}
+
+// HeaderContains expects one of the values of the response header key to
+// contain substr.
+func (e *Expectation) HeaderContains(key, substr string) *Expectation {
+	values := e.resp.Header().Values(key)
+	for _, v := range values {
+		if strings.Contains(v, substr) {
+			return e
+		}
+	}
+
+	return e.fail("expected header %q to contain %q, got %q", key, substr, values)
+}
+
+// NoHeader expects the response header key to be absent.
+func (e *Expectation) NoHeader(key string) *Expectation {
+	if values := e.resp.Header().Values(key); len(values) > 0 {
+		return e.fail("expected header %q to be absent, got %q", key, values)
+	}
+
+	return e
+}
+
+// ContentType expects the response media type. Parameters such as charset
+// are only compared when contentType contains them.
+func (e *Expectation) ContentType(contentType string) *Expectation {
+	got := e.resp.Header().Get(ContentType)
+	if got == "" {
+		return e.fail("expected content type %q, but it is missing", contentType)
+	}
+
+	wantType, wantParams, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return e.fail("invalid expected content type %q: %v", contentType, err)
+	}
+
+	gotType, gotParams, err := mime.ParseMediaType(got)
+	if err != nil {
+		return e.fail("expected content type %q, got malformed %q", contentType, got)
+	}
+
+	if wantType != gotType {
+		return e.fail("expected content type %q, got %q", contentType, got)
+	}
+
+	for k, v := range wantParams {
+		if !strings.EqualFold(gotParams[k], v) {
+			return e.fail("expected content type %q, got %q", contentType, got)
+		}
+	}
+
+	return e
+}
+
+// Cookie expects the response to set the cookie name with value.
+func (e *Expectation) Cookie(name, value string) *Expectation {
+	for _, c := range e.resp.Result().Cookies() {
+		if c.Name == name {
+			if c.Value != value {
+				return e.fail("expected cookie %q to be %q, got %q", name, value, c.Value)
+			}
+			return e
+		}
+	}
+
+	return e.fail("expected cookie %q to be set", name)
+}
+
+// Body expects the response body to equal body.
+func (e *Expectation) Body(body string) *Expectation {
+	if got := e.resp.Body.String(); got != body {
+		return e.fail("expected body %q, got %q", body, got)
+	}
+
+	return e
+}
+
+// BodyContains expects the response body to contain substr.
+func (e *Expectation) BodyContains(substr string) *Expectation {
+	if got := e.resp.Body.String(); !strings.Contains(got, substr) {
+		return e.fail("expected body to contain %q, got %q", substr, got)
+	}
+
+	return e
+}
+
+// BodyEmpty expects the response body to be empty.
+func (e *Expectation) BodyEmpty() *Expectation {
+	if n := e.resp.Body.Len(); n > 0 {
+		return e.fail("expected empty body, got %d bytes", n)
+	}
+
+	return e
+}
+
+// Chunks expects the body to have been flushed as chunks, see
+// Recorder.Chunks: every flush ends a chunk, and the bytes written after
+// the last flush form the last one.
+func (e *Expectation) Chunks(chunks ...string) *Expectation {
+	var got []string
+	for _, chunk := range e.resp.Recorder.Chunks() {
+		got = append(got, string(chunk))
+	}
+
+	if !slices.Equal(got, chunks) {
+		return e.fail("expected body chunks %q, got %q", chunks, got)
+	}
+
+	return e
+}
+
+// FirstByteWithin expects the handler to write the first byte of the body
+// within d, see Recorder.TimeToFirstByte.
+func (e *Expectation) FirstByteWithin(d time.Duration) *Expectation {
+	ttfb, ok := e.resp.Recorder.TimeToFirstByte()
+	switch {
+	case !ok:
+		return e.fail("expected first byte within %s, but the body is empty", d)
+	case ttfb > d:
+		return e.fail("expected first byte within %s, got %s", d, ttfb)
+	}
+
+	return e
+}
+
+// NoSuperfluousWriteHeader expects the handler not to call WriteHeader
+// once the header was written.
+func (e *Expectation) NoSuperfluousWriteHeader() *Expectation {
+	for _, call := range e.resp.Recorder.Superfluous() {
+		e.fail("unexpected superfluous WriteHeader(%d) after %s", call.Code, call.Elapsed)
+	}
+
+	return e
+}
+
+// JSON expects the response body to be JSON semantically equal to want,
+// ignoring formatting and key order. want may be raw JSON as a string,
+// []byte or json.RawMessage, or any value that marshals to JSON.
+func (e *Expectation) JSON(want any) *Expectation {
+	raw, wantValue, err := decodeExpectedJSON(want)
+	if err != nil {
+		return e.fail("invalid expected JSON: %v", err)
+	}
+
+	gotValue, err := decodeJSON(e.resp.Body.Bytes())
+	if err != nil {
+		return e.fail("expected JSON body, got %q: %v", e.resp.Body.String(), err)
+	}
+
+	if !reflect.DeepEqual(wantValue, gotValue) {
+		return e.fail("expected JSON body %s, got %s", bytes.TrimSpace(raw), bytes.TrimSpace(e.resp.Body.Bytes()))
+	}
+
+	return e
+}
+
+// decodeExpectedJSON decodes an expected JSON value given as a string,
+// bytes, json.RawMessage or any value to marshal. It returns the JSON text
+// and the normalized value.
+func decodeExpectedJSON(want any) ([]byte, any, error) {
+	var raw []byte
+	switch v := want.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	case json.RawMessage:
+		raw = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		raw = b
+	}
+
+	v, err := decodeJSON(raw)
+
+	return raw, v, err
+}
+
+// decodeJSON decodes a single JSON document. Numbers are normalized to their
+// exact rational form so that 1, 1.0 and 1e0 compare equal and large
+// integers do not lose precision.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	if dec.More() {
+		return nil, errors.New("unexpected data after top-level value")
+	}
+
+	return normalizeJSON(v), nil
+}
+
+// canonicalNumber is the normalized form of a JSON number, kept distinct
+// from string so that 1 and "1" never compare equal.
+type canonicalNumber string
+
+// String formats the number in decimal notation.
+func (n canonicalNumber) String() string {
+	r, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return string(n)
+	}
+
+	return ratString(r)
+}
+
+// MarshalJSON encodes the number as a JSON number.
+func (n canonicalNumber) MarshalJSON() ([]byte, error) {
+	return []byte(n.String()), nil
+}
+
+// ratString formats a rational parsed from a JSON number in the shortest
+// exact decimal notation.
+func ratString(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	for prec := 1; prec < 1100; prec++ {
+		s := r.FloatString(prec)
+		if v, ok := new(big.Rat).SetString(s); ok && v.Cmp(r) == 0 {
+			return s
+		}
+	}
+
+	return r.RatString()
+}
+
+// normalizeJSON replaces every json.Number in v with its canonicalNumber.
+func normalizeJSON(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if r, ok := new(big.Rat).SetString(v.String()); ok {
+			return canonicalNumber(r.RatString())
+		}
+		return canonicalNumber(v.String())
+	case map[string]any:
+		for k, item := range v {
+			v[k] = normalizeJSON(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeJSON(item)
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,114 @@
+package gofight
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createdHandler(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Location", "/users/1")
+	w.WriteHeader(http.StatusCreated)
+	_, _ = io.WriteString(w, `{"id": 1, "name": "foo", "tags": ["a", "b"]}`)
+}
+
+func expectEngine() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", createdHandler)
+	mux.HandleFunc("/", basicHelloHandler)
+
+	return mux
+}
+
+// TestExpectPass tests a chain where every expectation is met
+func TestExpectPass(t *testing.T) {
+	tb := &recordingTB{TB: t}
+
+	NewT(tb).POST("/users").
+		SetJSON(D{"name": "foo"}).
+		Run(expectEngine(), func(r HTTPResponse, rq HTTPRequest) {
+			e := r.Expect().
+				Status(http.StatusCreated).
+				Header("Location", "/users/1").
+				HeaderContains("content-type", "charset").
+				NoHeader("X-Missing").
+				ContentType("application/json").
+				ContentType("application/json; charset=UTF-8").
+				Cookie("session", "abc").
+				BodyContains(`"name": "foo"`).
+				JSON(`{"name":"foo","tags":["a","b"],"id":1.0}`).
+				JSON(map[string]any{"id": 1, "name": "foo", "tags": []string{"a", "b"}})
+			assert.NoError(t, e.Err())
+		})
+
+	assert.Empty(t, tb.errors)
+}
+
+// TestExpectCollectsFailures tests that every mismatch is reported together
+func TestExpectCollectsFailures(t *testing.T) {
+	tb := &recordingTB{TB: t}
+
+	NewT(tb).GET("/").
+		Run(expectEngine(), func(r HTTPResponse, rq HTTPRequest) {
+			r.Expect().
+				Status(http.StatusCreated).
+				Header("Location", "/users/1").
+				ContentType("application/json").
+				Body("Hello").
+				BodyEmpty().
+				JSON(`{"id": "1"}`)
+			r.Expect().Cookie("session", "abc")
+		})
+
+	require.Len(t, tb.errors, 1)
+	msg := tb.errors[0]
+	assert.Contains(t, msg, "gofight: GET /: 7 expectation(s) failed")
+	assert.Contains(t, msg, "expected status 201 Created, got 200 OK")
+	assert.Contains(t, msg, `expected header "Location" to be "/users/1", but it is missing`)
+	assert.Contains(t, msg, `expected content type "application/json", got "text/plain"`)
+	assert.Contains(t, msg, `expected body "Hello", got "Hello World"`)
+	assert.Contains(t, msg, "expected empty body, got 11 bytes")
+	assert.Contains(t, msg, "expected JSON body")
+	assert.Contains(t, msg, `expected cookie "session" to be set`)
+}
+
+// TestExpectJSONNumberVsString tests that numbers and strings never compare equal
+func TestExpectJSONNumberVsString(t *testing.T) {
+	New().POST("/users").
+		Run(expectEngine(), func(r HTTPResponse, rq HTTPRequest) {
+			e := &Expectation{resp: r}
+			assert.Error(t, e.JSON(`{"id": "1", "name": "foo", "tags": ["a", "b"]}`).Err())
+		})
+}
+
+// TestExpectWithoutT tests that failures are returned when no test is bound
+func TestExpectWithoutT(t *testing.T) {
+	failing := func(r HTTPResponse, rq HTTPRequest) {
+		r.Expect().Status(http.StatusNotFound)
+	}
+
+	err := New().GET("/").RunE(expectEngine(), failing)
+	assert.EqualError(t, err, "1 expectation(s) failed:\n\texpected status 404 Not Found, got 200 OK")
+
+	assert.PanicsWithValue(t, "gofight: GET /: 1 expectation(s) failed:\n\texpected status 404 Not Found, got 200 OK",
+		func() { New().GET("/").Run(expectEngine(), failing) })
+
+	rc := New().GET("/")
+	rc.Run(expectEngine(), func(r HTTPResponse, rq HTTPRequest) {
+		r.Expect().Status(http.StatusOK)
+	})
+	assert.NoError(t, rc.Err())
+
+	// A panicking ResponseFunc is not hidden by its failed expectations.
+	assert.PanicsWithValue(t, "boom", func() {
+		New().GET("/").Run(expectEngine(), func(r HTTPResponse, rq HTTPRequest) {
+			r.Expect().Status(http.StatusNotFound)
+			panic("boom")
+		})
+	})
+}
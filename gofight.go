@@ -51,17 +51,21 @@ package gofight
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"mime/multipart"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"testing"
 )
 
 // Media types
@@ -71,12 +75,25 @@ const (
 	ContentType     = "Content-Type"
 	ApplicationJSON = "application/json"
 	ApplicationForm = "application/x-www-form-urlencoded"
+
+	ApplicationOctetStream = "application/octet-stream"
+	ApplicationNDJSON      = "application/x-ndjson"
 )
 
-// HTTPResponse wraps the httptest.ResponseRecorder to provide additional
-// functionality or to simplify the response handling in tests.
+// HTTPResponse wraps the Recorder, an httptest.ResponseRecorder keeping
+// the timeline of the handler calls, to provide additional functionality
+// or to simplify the response handling in tests.
+//
+// HTTPResponse used to embed *httptest.ResponseRecorder directly. The
+// fields and methods of the ResponseRecorder are still promoted, but the
+// struct can no longer be built outside gofight, as HTTPResponse{w} or
+// with a ResponseRecorder key: it is only built by Run and RunE.
 type HTTPResponse struct {
-	*httptest.ResponseRecorder
+	*Recorder
+
+	// expect collects the expectations created with Expect so that Run can
+	// report them once the ResponseFunc returns.
+	expect *expectations
 }
 
 // HTTPRequest is a wrapper around the standard http.Request.
@@ -107,6 +124,50 @@ type RequestConfig struct {
 	Debug       bool
 	ContentType string
 	Context     context.Context
+
+	// errs collects every error reported while building the request.
+	errs []error
+	// t routes diagnostics and failures to the test, see NewT.
+	t testing.TB
+
+	// bodyBytes and bodyReader replace Body when set with SetBodyBytes or
+	// SetBodyReader.
+	bodyBytes  []byte
+	bodyReader io.Reader
+	// contentLength overrides the request ContentLength when
+	// contentLengthSet is true; -1 sends the body with unknown length.
+	contentLength    int64
+	contentLengthSet bool
+
+	// headerOps are the changes made by SetHeader, SetHeaders, AddHeader and
+	// DelHeader, replayed in order over the default headers.
+	headerOps []func(http.Header)
+
+	// session carries cookies across requests, see NewSession.
+	session *Session
+
+	// cookies and rawCookies are sent after Cookies, see AddCookies and
+	// SetRawCookie.
+	cookies    []*http.Cookie
+	rawCookies []string
+	// secure makes the request look as if made over TLS.
+	secure bool
+	// digest answers Digest challenges, see SetDigestAuth.
+	digest *digestAuth
+
+	// mode and serverConfigs select how the request is executed, see
+	// SetMode.
+	mode          Mode
+	serverConfigs []func(*httptest.Server)
+
+	// tlsCert, clientCerts and clientCAs configure ModeTLS.
+	tlsCert     *tls.Certificate
+	clientCerts []tls.Certificate
+	clientCAs   *x509.CertPool
+
+	// baseURL and client are used by ModeRemote, see NewRemote.
+	baseURL string
+	client  *http.Client
 }
 
 // UploadFile for upload file struct
@@ -123,6 +184,54 @@ func New() *RequestConfig {
 	}
 }
 
+// NewT supply initial structure bound to a test. Debug output is written
+// with t.Logf so it appears under the right (sub)test, and Run reports
+// request-building errors with t.Errorf, pointing at the caller line,
+// instead of executing a request that is not what the test asked for.
+func NewT(t testing.TB) *RequestConfig {
+	rc := New()
+	rc.t = t
+
+	return rc
+}
+
+// logf writes debug output to the bound test, or to the standard logger.
+func (rc *RequestConfig) logf(format string, args ...any) {
+	if rc.t != nil {
+		rc.t.Helper()
+		rc.t.Logf(format, args...)
+		return
+	}
+
+	log.Printf(format, args...)
+}
+
+// errorf reports a failure to the bound test. Without a bound test the
+// failure is logged with the standard logger.
+func (rc *RequestConfig) errorf(format string, args ...any) {
+	if rc.t != nil {
+		rc.t.Helper()
+		rc.t.Errorf(format, args...)
+		return
+	}
+
+	log.Printf(format, args...)
+}
+
+// Err returns every error recorded while building the request, joined
+// with errors.Join, or nil when the request was built successfully.
+func (rc *RequestConfig) Err() error {
+	return errors.Join(rc.errs...)
+}
+
+// addError records a request-building error so it can be surfaced by Err
+// and RunE instead of being silently dropped.
+func (rc *RequestConfig) addError(err error) {
+	if err != nil {
+		rc.errs = append(rc.errs, err)
+	}
+}
+
 // SetDebug supply enable debug mode.
 func (rc *RequestConfig) SetDebug(enable bool) *RequestConfig {
 	rc.Debug = enable
@@ -189,24 +298,94 @@ func (rc *RequestConfig) OPTIONS(path string) *RequestConfig {
 	return rc.setHTTPMethod("OPTIONS", path)
 }
 
-// SetHeader supply http header what you defined.
+// SetHeader supply http header what you defined. Headers are merged with
+// those set by earlier calls; a key that is set again is replaced.
 func (rc *RequestConfig) SetHeader(headers H) *RequestConfig {
-	if len(headers) > 0 {
-		rc.Headers = headers
+	if len(headers) == 0 {
+		return rc
+	}
+
+	merged := maps.Clone(rc.Headers)
+	if merged == nil {
+		merged = H{}
+	}
+	for k, v := range headers {
+		merged[k] = v
+		rc.headerOps = append(rc.headerOps, func(h http.Header) {
+			h.Set(k, v)
+		})
 	}
+	rc.Headers = merged
 
 	return rc
 }
 
+// SetHeaders sets multi-value headers. Each given key replaces the values
+// set earlier, including the default User-Agent and Content-Type; other
+// headers are kept.
+func (rc *RequestConfig) SetHeaders(headers http.Header) *RequestConfig {
+	for k, v := range headers {
+		values := slices.Clone(v)
+		rc.deleteHeaderKey(k)
+		rc.headerOps = append(rc.headerOps, func(h http.Header) {
+			h.Del(k)
+			for _, value := range values {
+				h.Add(k, value)
+			}
+		})
+	}
+
+	return rc
+}
+
+// AddHeader appends values to a header, keeping the values set earlier, so
+// repeated headers such as Accept, Forwarded or Via can be sent.
+//
+//	r.GET("/").
+//	  AddHeader("Accept", "application/json").
+//	  AddHeader("Accept", "text/plain")
+func (rc *RequestConfig) AddHeader(key string, values ...string) *RequestConfig {
+	rc.headerOps = append(rc.headerOps, func(h http.Header) {
+		for _, value := range values {
+			h.Add(key, value)
+		}
+	})
+
+	return rc
+}
+
+// DelHeader removes headers from the request. It also removes the
+// User-Agent and Content-Type headers that are otherwise added by default.
+func (rc *RequestConfig) DelHeader(keys ...string) *RequestConfig {
+	for _, k := range keys {
+		rc.deleteHeaderKey(k)
+		rc.headerOps = append(rc.headerOps, func(h http.Header) {
+			h.Del(k)
+		})
+	}
+
+	return rc
+}
+
+// deleteHeaderKey removes key from Headers, whatever its case.
+func (rc *RequestConfig) deleteHeaderKey(key string) {
+	key = http.CanonicalHeaderKey(key)
+	rc.Headers = maps.Clone(rc.Headers)
+	for k := range rc.Headers {
+		if http.CanonicalHeaderKey(k) == key {
+			delete(rc.Headers, k)
+		}
+	}
+}
+
 // SetJSON supply JSON body.
 func (rc *RequestConfig) SetJSON(body D) *RequestConfig {
 	b, err := json.Marshal(body)
 	if err != nil {
-		// Log error but continue to maintain backward compatibility
-		log.Printf("SetJSON: failed to marshal JSON: %v", err)
+		rc.addError(fmt.Errorf("SetJSON: failed to marshal JSON: %w", err))
 		return rc
 	}
-	rc.Body = string(b)
+	rc.setBody(string(b))
 	return rc
 }
 
@@ -214,11 +393,10 @@ func (rc *RequestConfig) SetJSON(body D) *RequestConfig {
 func (rc *RequestConfig) SetJSONInterface(body any) *RequestConfig {
 	b, err := json.Marshal(body)
 	if err != nil {
-		// Log error but continue to maintain backward compatibility
-		log.Printf("SetJSONInterface: failed to marshal JSON: %v", err)
+		rc.addError(fmt.Errorf("SetJSONInterface: failed to marshal JSON: %w", err))
 		return rc
 	}
-	rc.Body = string(b)
+	rc.setBody(string(b))
 	return rc
 }
 
@@ -240,72 +418,32 @@ func (rc *RequestConfig) SetForm(body H) *RequestConfig {
 		f.Set(k, v)
 	}
 
-	rc.Body = f.Encode()
+	rc.setBody(f.Encode())
 
 	return rc
 }
 
-// SetFileFromPath upload new file.
+// SetFileFromPath upload new file. Every file is sent as
+// application/octet-stream followed by the fields of params, in key order.
+// Use SetMultipart for full control over the parts.
 func (rc *RequestConfig) SetFileFromPath(uploads []UploadFile, params ...H) *RequestConfig {
-	body := new(bytes.Buffer)
-	writer := multipart.NewWriter(body)
-	defer func() {
-		if err := writer.Close(); err != nil {
-			log.Printf("SetFileFromPath: failed to close writer: %v", err)
-		}
-	}()
+	m := NewMultipart()
 
 	for _, f := range uploads {
-		if err := rc.processUploadFile(writer, f); err != nil {
-			log.Printf("SetFileFromPath: failed to process file %s: %v", f.Path, err)
-			continue // Continue processing other files instead of returning
+		if len(f.Content) > 0 {
+			m.FileBytes(f.Name, filepath.Base(f.Path), f.Content, PartContentType(ApplicationOctetStream))
+		} else {
+			m.FileFromPath(f.Name, f.Path, PartContentType(ApplicationOctetStream))
 		}
 	}
 
 	if len(params) > 0 {
-		for key, val := range params[0] {
-			if err := writer.WriteField(key, val); err != nil {
-				log.Printf("SetFileFromPath: failed to write field %s: %v", key, err)
-			}
+		for _, key := range slices.Sorted(maps.Keys(params[0])) {
+			m.Field(key, params[0][key])
 		}
 	}
 
-	rc.ContentType = writer.FormDataContentType()
-	rc.Body = body.String()
-
-	return rc
-}
-
-// processUploadFile handles the processing of a single upload file.
-func (rc *RequestConfig) processUploadFile(writer *multipart.Writer, f UploadFile) error {
-	reader := bytes.NewReader(f.Content)
-	if reader.Size() == 0 {
-		// Open file and ensure it's closed properly
-		file, err := os.Open(f.Path)
-		if err != nil {
-			return fmt.Errorf("failed to open file %s: %w", f.Path, err)
-		}
-		defer file.Close()
-
-		part, err := writer.CreateFormFile(f.Name, filepath.Base(f.Path))
-		if err != nil {
-			return fmt.Errorf("failed to create form file for %s: %w", f.Name, err)
-		}
-
-		if _, err = io.Copy(part, file); err != nil {
-			return fmt.Errorf("failed to copy file content: %w", err)
-		}
-	} else {
-		part, err := writer.CreateFormFile(f.Name, filepath.Base(f.Path))
-		if err != nil {
-			return fmt.Errorf("failed to create form file for %s: %w", f.Name, err)
-		}
-
-		if _, err = reader.WriteTo(part); err != nil {
-			return fmt.Errorf("failed to write content: %w", err)
-		}
-	}
-	return nil
+	return rc.setMultipart("SetFileFromPath", m)
 }
 
 // isJSONContent checks if the body content appears to be JSON.
@@ -315,12 +453,20 @@ func (rc *RequestConfig) isJSONContent(body string) bool {
 		(strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"))
 }
 
-// isSecureContext determines if cookies should be set as secure.
-// For testing purposes, this returns false, but can be overridden based on context.
+// isSecureContext reports whether the request is made as if over TLS, see
+// SetSecureContext.
 func (rc *RequestConfig) isSecureContext() bool {
-	// In a real application, you might check for HTTPS or environment variables
-	// For testing framework, we default to false but this can be made configurable
-	return false
+	return rc.secure
+}
+
+// SetSecureContext makes the request look as if it was received over TLS:
+// Request.TLS is set, so handlers that check for HTTPS, such as those
+// issuing Secure cookies, behave as in production. A Session also replays
+// its Secure cookies on such requests.
+func (rc *RequestConfig) SetSecureContext(secure bool) *RequestConfig {
+	rc.secure = secure
+
+	return rc
 }
 
 // SetPath supply new request path to deal with path variable request
@@ -333,41 +479,19 @@ func (rc *RequestConfig) SetPath(str string) *RequestConfig {
 // SetQueryD supply query string, support query using string array input.
 // ex. /reqpath/?Ids[]=E&Ids[]=M usage:
 // IDArray:=[]string{"E","M"} r.GET("reqpath").SetQueryD(gofight.D{`Ids[]`: IDArray})
+//
+// Keys are sorted. Numbers, bools, time.Time and encoding.TextMarshaler
+// values are formatted, and nested maps use brackets:
+// D{"filter": D{"status": []string{"open"}}} encodes filter[status][]=open.
+// Values that cannot be encoded are reported by Err.
 func (rc *RequestConfig) SetQueryD(query D) *RequestConfig {
-	if len(query) == 0 {
+	fields, err := encodeD(query)
+	if err != nil {
+		rc.addError(fmt.Errorf("SetQueryD: %w", err))
 		return rc
 	}
 
-	var buf strings.Builder
-	buf.WriteString("?")
-	first := true
-
-	for k, v := range query {
-		switch v := v.(type) {
-		case string:
-			if !first {
-				buf.WriteString("&")
-			}
-			buf.WriteString(url.QueryEscape(k))
-			buf.WriteString("=")
-			buf.WriteString(url.QueryEscape(v))
-			first = false
-		case []string:
-			for _, info := range v {
-				if !first {
-					buf.WriteString("&")
-				}
-				buf.WriteString(url.QueryEscape(k))
-				buf.WriteString("=")
-				buf.WriteString(url.QueryEscape(info))
-				first = false
-			}
-		}
-	}
-
-	// Avoid calling buf.String() twice
-	queryStr := buf.String()
-	rc.Path += queryStr
+	rc.appendQuery(encodeFields(fields))
 	return rc
 }
 
@@ -391,11 +515,7 @@ func (rc *RequestConfig) SetQuery(query H) *RequestConfig {
 		f.Set(k, v)
 	}
 
-	if strings.Contains(rc.Path, "?") {
-		rc.Path = rc.Path + "&" + f.Encode()
-	} else {
-		rc.Path = rc.Path + "?" + f.Encode()
-	}
+	rc.appendQuery(f.Encode())
 
 	return rc
 }
@@ -410,15 +530,137 @@ func (rc *RequestConfig) SetQuery(query H) *RequestConfig {
 //   - *RequestConfig: The updated RequestConfig instance.
 func (rc *RequestConfig) SetBody(body string) *RequestConfig {
 	if len(body) > 0 {
-		rc.Body = body
+		rc.setBody(body)
 	}
 
 	return rc
 }
 
+// setBody replaces the request body with a string, discarding any body set
+// with SetBodyBytes or SetBodyReader.
+func (rc *RequestConfig) setBody(body string) {
+	rc.Body = body
+	rc.bodyBytes = nil
+	rc.bodyReader = nil
+}
+
+// SetBodyBytes sets a binary request body without converting it to a
+// string. The request has a known Content-Length and Request.GetBody is
+// populated so the body can be read again.
+func (rc *RequestConfig) SetBodyBytes(body []byte) *RequestConfig {
+	if len(body) > 0 {
+		rc.setBody("")
+		rc.bodyBytes = body
+	}
+
+	return rc
+}
+
+// SetBodyReader streams the request body from r, which is read lazily by
+// the handler. Unless SetContentLength is used the body has an unknown
+// length and is sent chunked. Request.GetBody is populated when r is an
+// io.Seeker, by rewinding it to its current position.
+func (rc *RequestConfig) SetBodyReader(r io.Reader) *RequestConfig {
+	if r != nil {
+		rc.setBody("")
+		rc.bodyReader = r
+	}
+
+	return rc
+}
+
+// SetContentLength sets an explicit Content-Length for the request body.
+// Pass -1 to send the body with an unknown length, see SetChunked.
+func (rc *RequestConfig) SetContentLength(n int64) *RequestConfig {
+	if n < -1 {
+		rc.addError(fmt.Errorf("SetContentLength: invalid length %d", n))
+		return rc
+	}
+
+	rc.contentLength = n
+	rc.contentLengthSet = true
+
+	return rc
+}
+
+// SetChunked sends the request body with an unknown length using chunked
+// transfer encoding.
+func (rc *RequestConfig) SetChunked() *RequestConfig {
+	return rc.SetContentLength(-1)
+}
+
+// SetContentType sets the request Content-Type, overriding the automatic
+// detection.
+func (rc *RequestConfig) SetContentType(contentType string) *RequestConfig {
+	rc.ContentType = contentType
+
+	return rc
+}
+
+// requestBody returns the reader for the configured body.
+func (rc *RequestConfig) requestBody() io.Reader {
+	switch {
+	case rc.bodyReader != nil:
+		return rc.bodyReader
+	case rc.bodyBytes != nil:
+		return bytes.NewReader(rc.bodyBytes)
+	default:
+		return strings.NewReader(rc.Body)
+	}
+}
+
+// applyBody sets the body framing of req: content length, chunked transfer
+// encoding and GetBody for seekable readers.
+func (rc *RequestConfig) applyBody(req *http.Request) error {
+	if rc.bodyReader != nil {
+		req.ContentLength = -1
+		if seeker, ok := rc.bodyReader.(io.Seeker); ok {
+			start, err := seeker.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return fmt.Errorf("SetBodyReader: failed to seek body: %w", err)
+			}
+			reader := rc.bodyReader
+			req.GetBody = func() (io.ReadCloser, error) {
+				if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+					return nil, err
+				}
+				return io.NopCloser(reader), nil
+			}
+		}
+	}
+
+	if rc.contentLengthSet {
+		req.ContentLength = rc.contentLength
+	}
+
+	if req.ContentLength < 0 {
+		req.TransferEncoding = []string{"chunked"}
+	}
+
+	return nil
+}
+
+// defaultContentType guesses the Content-Type of the configured body.
+func (rc *RequestConfig) defaultContentType() string {
+	switch {
+	case rc.bodyReader != nil:
+		return ApplicationOctetStream
+	case rc.bodyBytes != nil:
+		if rc.isJSONContent(string(rc.bodyBytes)) {
+			return ApplicationJSON
+		}
+		return ApplicationOctetStream
+	case rc.isJSONContent(rc.Body):
+		return ApplicationJSON
+	default:
+		return ApplicationForm
+	}
+}
+
 // SetCookie sets the cookies for the request configuration.
-// It takes a map of cookies and assigns it to the Cookies field of the RequestConfig
-// if the provided map is not empty.
+// It takes a map of cookies and merges it into the Cookies field of the
+// RequestConfig, so a name set again is replaced. Cookies are sent in name
+// order.
 //
 // Parameters:
 //   - cookies: A map of cookies to be set.
@@ -427,32 +669,52 @@ func (rc *RequestConfig) SetBody(body string) *RequestConfig {
 //   - A pointer to the updated RequestConfig.
 func (rc *RequestConfig) SetCookie(cookies H) *RequestConfig {
 	if len(cookies) > 0 {
-		rc.Cookies = cookies
+		merged := maps.Clone(rc.Cookies)
+		if merged == nil {
+			merged = H{}
+		}
+		maps.Copy(merged, cookies)
+		rc.Cookies = merged
 	}
 
 	return rc
 }
 
-func (rc *RequestConfig) initTest() (*http.Request, *httptest.ResponseRecorder) {
-	qs := ""
-	if strings.Contains(rc.Path, "?") {
-		ss := strings.Split(rc.Path, "?")
-		rc.Path = ss[0]
-		qs = ss[1]
+// AddCookies adds cookies to the request, after those set with SetCookie and
+// in the given order. Names may repeat. Only the name and value are sent,
+// as with a browser; values are quoted when needed by http.Request.AddCookie.
+func (rc *RequestConfig) AddCookies(cookies ...*http.Cookie) *RequestConfig {
+	rc.cookies = append(rc.cookies, cookies...)
+
+	return rc
+}
+
+// SetRawCookie appends raw to the Cookie header verbatim, e.g.
+// `a=1; a=2; token="x y"`, to reproduce headers sent by real browsers,
+// with duplicate names or values that AddCookies would sanitize.
+func (rc *RequestConfig) SetRawCookie(raw string) *RequestConfig {
+	if raw != "" {
+		rc.rawCookies = append(rc.rawCookies, raw)
 	}
 
-	body := bytes.NewBufferString(rc.Body)
+	return rc
+}
+
+// newRequest builds the *http.Request described by the RequestConfig.
+func (rc *RequestConfig) newRequest() (*http.Request, error) {
+	// The query is split off by hand so that it is sent as written, and
+	// rc.Path is left untouched for the next request built from rc.
+	path, qs, _ := strings.Cut(rc.Path, "?")
 
-	req, err := http.NewRequestWithContext(rc.Context, rc.Method, rc.Path, body)
+	req, err := http.NewRequestWithContext(rc.Context, rc.Method, path, rc.requestBody())
 	if err != nil {
-		log.Printf("initTest: failed to create HTTP request: %v", err)
-		// Create minimal request to prevent panic
-		req, _ = http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+		return nil, fmt.Errorf("initTest: failed to create HTTP request: %w", err)
 	}
+	req.URL.RawQuery = qs
 	req.RequestURI = req.URL.RequestURI()
 
-	if len(qs) > 0 {
-		req.URL.RawQuery = qs
+	if err := rc.applyBody(req); err != nil {
+		return nil, err
 	}
 
 	// Auto add user agent
@@ -461,11 +723,7 @@ func (rc *RequestConfig) initTest() (*http.Request, *httptest.ResponseRecorder)
 	if rc.Method == http.MethodPost ||
 		rc.Method == http.MethodPut ||
 		rc.Method == http.MethodPatch {
-		if rc.isJSONContent(rc.Body) {
-			req.Header.Set(ContentType, ApplicationJSON)
-		} else {
-			req.Header.Set(ContentType, ApplicationForm)
-		}
+		req.Header.Set(ContentType, rc.defaultContentType())
 	}
 
 	if rc.ContentType != "" {
@@ -478,32 +736,74 @@ func (rc *RequestConfig) initTest() (*http.Request, *httptest.ResponseRecorder)
 		}
 	}
 
-	if len(rc.Cookies) > 0 {
-		for k, v := range rc.Cookies {
-			// Secure is context-aware: a test client may exercise HTTP-only flows.
-			req.AddCookie(&http.Cookie{ //nolint:gosec
-				Name:     k,
-				Value:    v,
-				HttpOnly: true,
-				Secure:   rc.isSecureContext(),
-				SameSite: http.SameSiteStrictMode,
-			})
+	for _, k := range slices.Sorted(maps.Keys(rc.Cookies)) {
+		req.AddCookie(&http.Cookie{Name: k, Value: rc.Cookies[k]})
+	}
+	for _, c := range rc.cookies {
+		req.AddCookie(c)
+	}
+	for _, raw := range rc.rawCookies {
+		if c := req.Header.Get("Cookie"); c != "" {
+			raw = c + "; " + raw
+		}
+		req.Header.Set("Cookie", raw)
+	}
+
+	if rc.isSecureContext() {
+		req.TLS = &tls.ConnectionState{
+			Version:           tls.VersionTLS13,
+			HandshakeComplete: true,
 		}
 	}
 
+	for _, op := range rc.headerOps {
+		op(req.Header)
+	}
+
 	if rc.Debug {
-		log.Printf("Request QueryString: %s", qs)
-		log.Printf("Request Method: %s", rc.Method)
-		log.Printf("Request Path: %s", rc.Path)
-		log.Printf("Request Body: %s", rc.Body)
-		log.Printf("Request Headers: %+v", rc.Headers)
-		log.Printf("Request Cookies: %+v", rc.Cookies)
-		log.Printf("Request Header: %+v", req.Header)
+		if rc.t != nil {
+			rc.t.Helper()
+		}
+		rc.logf("Request QueryString: %s", qs)
+		rc.logf("Request Method: %s", rc.Method)
+		rc.logf("Request Path: %s", rc.Path)
+		switch {
+		case rc.bodyReader != nil:
+			rc.logf("Request Body: <streamed, length %d>", req.ContentLength)
+		case rc.bodyBytes != nil:
+			rc.logf("Request Body: <%d bytes>", len(rc.bodyBytes))
+		default:
+			rc.logf("Request Body: %s", rc.Body)
+		}
+		rc.logf("Request Headers: %+v", rc.Headers)
+		rc.logf("Request Cookies: %+v", rc.Cookies)
+		rc.logf("Request Header: %+v", req.Header)
 	}
 
-	w := httptest.NewRecorder()
+	return req, nil
+}
+
+// initTest builds the request. Errors are recorded on
+// the RequestConfig; a request that cannot be built falls back to GET / so
+// that Run keeps its historical behaviour. Use RunE to fail fast instead.
+func (rc *RequestConfig) initTest() *http.Request {
+	if rc.t != nil {
+		rc.t.Helper()
+	}
 
-	return req, w
+	req, err := rc.newRequest()
+	if err != nil {
+		rc.addError(err)
+		// Create minimal request to prevent panic
+		req, _ = http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+		req.RequestURI = req.URL.RequestURI()
+	}
+
+	if err := rc.Err(); err != nil {
+		rc.logf("gofight: request built with errors: %v", err)
+	}
+
+	return req
 }
 
 // Run executes the HTTP request using the provided http.Handler and processes
@@ -511,18 +811,159 @@ func (rc *RequestConfig) initTest() (*http.Request, *httptest.ResponseRecorder)
 // and response writer, serves the HTTP request, and then passes the HTTP
 // response and request to the response function.
 //
+// Without a bound test, request-building errors are logged and kept for
+// Err, and the request is executed as far as it could be built, but failed
+// expectations panic, as there is no test to report them to. So does a
+// request that could not be sent, e.g. to a server that is not running,
+// without calling the response function. Use RunE to surface those errors
+// instead. A RequestConfig created with NewT reports them with t.Errorf
+// and skips the request.
+//
 // Parameters:
 //   - r: The http.Handler that will handle the HTTP request.
 //   - response: A function that processes the HTTP response and request.
 func (rc *RequestConfig) Run(r http.Handler, response ResponseFunc) {
-	req, w := rc.initTest()
-	r.ServeHTTP(w, req)
+	if rc.t != nil {
+		rc.t.Helper()
+		if err := rc.RunE(r, response); err != nil {
+			rc.errorf("gofight: %s %s: %v", rc.Method, rc.Path, err)
+		}
+		return
+	}
+
+	req := rc.initTest()
+	h, err := rc.handler(r)
+	if err != nil {
+		rc.addError(err)
+		rc.logf("gofight: %v", err)
+		h = http.NotFoundHandler()
+	}
+	w, req, err := rc.serve(h, req)
+	if err != nil {
+		// There is no response to hand to the ResponseFunc: calling it
+		// with an empty Recorder would let status assertions pass.
+		panic(fmt.Sprintf("gofight: %s %s: %v", rc.Method, rc.Path, err))
+	}
+	if err := rc.respond(w, req, response); err != nil {
+		panic(fmt.Sprintf("gofight: %s %s: %v", rc.Method, rc.Path, err))
+	}
+}
+
+// RunE behaves like Run but refuses to execute a request that was not built
+// as asked. When any builder method or the request construction itself
+// failed, the handler and the response function are not called and the
+// aggregated error is returned. Otherwise it returns the failed
+// expectations of the response, see Expectation.
+func (rc *RequestConfig) RunE(r http.Handler, response ResponseFunc) error {
+	if rc.t != nil {
+		rc.t.Helper()
+	}
+
+	if err := rc.Err(); err != nil {
+		return err
+	}
+
+	h, err := rc.handler(r)
+	if err != nil {
+		rc.addError(err)
+		return rc.Err()
+	}
+
+	req, err := rc.newRequest()
+	if err != nil {
+		rc.addError(err)
+		return rc.Err()
+	}
+
+	w, req, err := rc.serve(h, req)
+	if err != nil {
+		rc.addError(err)
+		return rc.Err()
+	}
+
+	return rc.respond(w, req, response)
+}
+
+// handler returns r, or the session handler when r is nil. ModeRemote
+// needs no handler.
+func (rc *RequestConfig) handler(r http.Handler) (http.Handler, error) {
+	switch {
+	case rc.mode == ModeRemote:
+		return r, nil
+	case r != nil:
+		return r, nil
+	case rc.session != nil && rc.session.handler != nil:
+		return rc.session.handler, nil
+	default:
+		return nil, errors.New("no handler to run the request")
+	}
+}
+
+// serve runs the request, exchanging cookies with the session if any. It
+// returns the final exchange: the request differs from req when it was
+// retried to answer a Digest challenge, or when it is the request received
+// by a server, see SetMode.
+func (rc *RequestConfig) serve(h http.Handler, req *http.Request) (*Recorder, *http.Request, error) {
+	exchange, stop, err := rc.exchanger(h)
+	if err != nil {
+		return NewRecorder(), req, err
+	}
+	defer stop()
+
+	secure := rc.isSecureContext() || rc.mode.secure()
+	cookies := slices.Clone(req.Header.Values("Cookie"))
+	if rc.session != nil {
+		rc.session.prepare(req, secure)
+	}
+
+	w, served, err := exchange(req)
+	if err != nil {
+		return w, served, err
+	}
+
+	if rc.session != nil {
+		rc.session.store(req, w.Result().Cookies(), secure)
+	}
+
+	if rc.digest == nil {
+		return w, served, nil
+	}
+
+	retry, err := rc.digest.answer(req, w.ResponseRecorder)
+	if err != nil || retry == nil {
+		return w, served, err
+	}
+	if rc.session != nil {
+		// Replace the session cookies of req, the challenge may have set
+		// new ones.
+		retry.Header["Cookie"] = cookies
+		if cookies == nil {
+			retry.Header.Del("Cookie")
+		}
+		rc.session.prepare(retry, secure)
+	}
+
+	w, served, err = exchange(retry)
+	if err == nil && rc.session != nil {
+		rc.session.store(retry, w.Result().Cookies(), secure)
+	}
+
+	return w, served, err
+}
+
+// respond hands the recorded exchange to the ResponseFunc and returns the
+// failures of the expectations it created once it returns.
+func (rc *RequestConfig) respond(w *Recorder, req *http.Request, response ResponseFunc) error {
+	exp := &expectations{}
 	response(
 		HTTPResponse{
-			w,
+			Recorder: w,
+			expect:   exp,
 		},
 		HTTPRequest{
 			req,
 		},
 	)
+
+	return exp.err()
 }
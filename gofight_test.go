@@ -218,6 +218,21 @@ func TestSetQueryWithExistingQuery(t *testing.T) {
 		})
 }
 
+// TestQueryKeptOnReuse tests that running a builder leaves its path and
+// query intact for the next run
+func TestQueryKeptOnReuse(t *testing.T) {
+	rc := NewT(t).GET("/query?a=1").SetQuery(H{"foo": "bar"})
+
+	for range 2 {
+		rc.Run(basicEngine(), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "a=1&foo=bar", rq.URL.RawQuery)
+			assert.Equal(t, "/query?a=1&foo=bar", rq.RequestURI)
+			assert.Equal(t, "bar", r.Body.String())
+		})
+	}
+	assert.Equal(t, "/query?a=1&foo=bar", rc.Path)
+}
+
 func TestSetForm(t *testing.T) {
 	r := New()
 	formData := H{
@@ -980,3 +995,318 @@ func TestHTTPResponseHeaderMethods(t *testing.T) {
 			assert.NotEmpty(t, resp.Header().Get("Content-Type"))
 		})
 }
+
+// TestRequestBuildErrors tests that builder errors are collected by Err
+func TestRequestBuildErrors(t *testing.T) {
+	t.Run("no errors", func(t *testing.T) {
+		r := New()
+		r.POST("/json").SetJSON(D{"a": 1})
+		assert.NoError(t, r.Err())
+	})
+
+	t.Run("json marshal errors are accumulated", func(t *testing.T) {
+		r := New()
+		r.POST("/json").
+			SetJSON(D{"ch": make(chan int)}).
+			SetJSONInterface(make(chan int))
+
+		err := r.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "SetJSON:")
+		assert.Contains(t, err.Error(), "SetJSONInterface:")
+
+		var typeErr *json.UnsupportedTypeError
+		assert.ErrorAs(t, err, &typeErr)
+	})
+
+	t.Run("missing upload file", func(t *testing.T) {
+		r := New()
+		r.POST("/upload").SetFileFromPath([]UploadFile{
+			{Path: filepath.Join("testdata", "missing.txt"), Name: "file"},
+			{Path: filepath.Join("testdata", "hello.txt"), Name: "hello"},
+		})
+
+		err := r.Err()
+		require.Error(t, err)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+// TestRunE tests that RunE refuses to execute requests built with errors
+func TestRunE(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		called := false
+		err := New().GET("/").
+			RunE(extendedEngine(), func(resp HTTPResponse, req HTTPRequest) {
+				called = true
+				assert.Equal(t, "Hello World", resp.Body.String())
+			})
+		assert.NoError(t, err)
+		assert.True(t, called)
+	})
+
+	t.Run("builder error", func(t *testing.T) {
+		err := New().POST("/json").
+			SetJSONInterface(make(chan int)).
+			RunE(extendedEngine(), func(resp HTTPResponse, req HTTPRequest) {
+				t.Error("response func must not be called")
+			})
+		assert.Error(t, err)
+	})
+
+	t.Run("malformed path", func(t *testing.T) {
+		r := New()
+		err := r.GET("/%zz").
+			RunE(extendedEngine(), func(resp HTTPResponse, req HTTPRequest) {
+				t.Error("response func must not be called")
+			})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to create HTTP request")
+		assert.Equal(t, err.Error(), r.Err().Error())
+	})
+
+	t.Run("invalid method", func(t *testing.T) {
+		err := New().setHTTPMethod("BAD METHOD", "/").
+			RunE(extendedEngine(), func(resp HTTPResponse, req HTTPRequest) {
+				t.Error("response func must not be called")
+			})
+		assert.Error(t, err)
+	})
+}
+
+// TestRunFallbackRecordsError tests that Run keeps going but records the error
+func TestRunFallbackRecordsError(t *testing.T) {
+	r := New()
+	r.GET("/%zz").
+		Run(extendedEngine(), func(resp HTTPResponse, req HTTPRequest) {
+			assert.Equal(t, "/", req.URL.Path)
+		})
+	assert.Error(t, r.Err())
+}
+
+// recordingTB captures the output sent to a testing.TB
+type recordingTB struct {
+	testing.TB
+	helpers int
+	logs    []string
+	errors  []string
+}
+
+func (tb *recordingTB) Helper() { tb.helpers++ }
+
+func (tb *recordingTB) Logf(format string, args ...any) {
+	tb.logs = append(tb.logs, fmt.Sprintf(format, args...))
+}
+
+func (tb *recordingTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+// TestNewT tests the testing.TB aware client
+func TestNewT(t *testing.T) {
+	t.Run("debug output goes to t.Logf", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		NewT(tb).GET("/").
+			SetDebug(true).
+			Run(extendedEngine(), func(resp HTTPResponse, req HTTPRequest) {
+				assert.Equal(t, http.StatusOK, resp.Code)
+			})
+
+		assert.NotEmpty(t, tb.logs)
+		assert.Contains(t, tb.logs[1], "Request Method: GET")
+		assert.Empty(t, tb.errors)
+		assert.Positive(t, tb.helpers)
+	})
+
+	t.Run("builder error reported with t.Errorf", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		NewT(tb).POST("/json").
+			SetJSONInterface(make(chan int)).
+			Run(extendedEngine(), func(resp HTTPResponse, req HTTPRequest) {
+				t.Error("response func must not be called")
+			})
+
+		require.Len(t, tb.errors, 1)
+		assert.Contains(t, tb.errors[0], "gofight: POST /json")
+		assert.Contains(t, tb.errors[0], "SetJSONInterface")
+	})
+
+	t.Run("parallel subtests", func(t *testing.T) {
+		for _, path := range []string{"/", "/method"} {
+			t.Run(path, func(t *testing.T) {
+				t.Parallel()
+				NewT(t).GET(path).
+					SetDebug(true).
+					Run(extendedEngine(), func(resp HTTPResponse, req HTTPRequest) {
+						assert.Equal(t, http.StatusOK, resp.Code)
+					})
+			})
+		}
+	})
+}
+
+func bodyInfoHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, _ = io.WriteString(w, fmt.Sprintf("len=%d te=%v body=%x", r.ContentLength, r.TransferEncoding, body))
+}
+
+// TestSetBodyBytes tests binary request bodies
+func TestSetBodyBytes(t *testing.T) {
+	payload := []byte{0x00, 0xff, 0x10, 0x80}
+
+	New().PUT("/").
+		SetBodyBytes(payload).
+		Run(http.HandlerFunc(bodyInfoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "len=4 te=[] body=00ff1080", r.Body.String())
+			assert.Equal(t, ApplicationOctetStream, rq.Header.Get(ContentType))
+
+			require.NotNil(t, rq.GetBody)
+			again, err := rq.GetBody()
+			require.NoError(t, err)
+			b, _ := io.ReadAll(again)
+			assert.Equal(t, payload, b)
+		})
+
+	New().POST("/").
+		SetBodyBytes([]byte(`{"a": 1}`)).
+		Run(http.HandlerFunc(bodyInfoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, ApplicationJSON, rq.Header.Get(ContentType))
+		})
+}
+
+// TestSetBodyReader tests streamed request bodies
+func TestSetBodyReader(t *testing.T) {
+	t.Run("unknown length is chunked", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		go func() {
+			for _, chunk := range []string{"ab", "cd"} {
+				_, _ = io.WriteString(pw, chunk)
+			}
+			_ = pw.Close()
+		}()
+
+		New().POST("/").
+			SetBodyReader(pr).
+			Run(http.HandlerFunc(bodyInfoHandler), func(r HTTPResponse, rq HTTPRequest) {
+				assert.Equal(t, "len=-1 te=[chunked] body=61626364", r.Body.String())
+				assert.Equal(t, ApplicationOctetStream, rq.Header.Get(ContentType))
+				assert.Nil(t, rq.GetBody)
+			})
+	})
+
+	t.Run("explicit length and seekable body", func(t *testing.T) {
+		New().POST("/").
+			SetBodyReader(strings.NewReader("hello")).
+			SetContentLength(5).
+			SetContentType("text/plain").
+			Run(http.HandlerFunc(bodyInfoHandler), func(r HTTPResponse, rq HTTPRequest) {
+				assert.Equal(t, "len=5 te=[] body=68656c6c6f", r.Body.String())
+				assert.Equal(t, "text/plain", rq.Header.Get(ContentType))
+
+				require.NotNil(t, rq.GetBody)
+				again, err := rq.GetBody()
+				require.NoError(t, err)
+				b, _ := io.ReadAll(again)
+				assert.Equal(t, "hello", string(b))
+			})
+	})
+
+	t.Run("chunked string body", func(t *testing.T) {
+		New().POST("/").
+			SetBody("abc").
+			SetChunked().
+			Run(http.HandlerFunc(bodyInfoHandler), func(r HTTPResponse, rq HTTPRequest) {
+				assert.Equal(t, "len=-1 te=[chunked] body=616263", r.Body.String())
+			})
+	})
+
+	t.Run("later body replaces reader", func(t *testing.T) {
+		New().POST("/").
+			SetBodyReader(strings.NewReader("stream")).
+			SetJSON(D{"a": 1}).
+			Run(http.HandlerFunc(bodyInfoHandler), func(r HTTPResponse, rq HTTPRequest) {
+				assert.Equal(t, ApplicationJSON, rq.Header.Get(ContentType))
+				assert.Contains(t, r.Body.String(), "len=7 ")
+			})
+	})
+
+	t.Run("invalid length", func(t *testing.T) {
+		assert.Error(t, New().SetContentLength(-2).Err())
+	})
+}
+
+// TestHeaderOperations tests additive, multi-value and deleted headers
+func TestHeaderOperations(t *testing.T) {
+	shared := func(rc *RequestConfig) *RequestConfig {
+		return rc.SetHeader(H{"X-Team": "core", "X-Version": "1"}).
+			AddHeader("Via", "1.1 proxy-a")
+	}
+
+	shared(New().POST("/")).
+		SetHeader(H{"X-Version": "2"}).
+		AddHeader("Accept", "application/json", "text/plain").
+		AddHeader("via", "1.1 proxy-b").
+		SetHeaders(http.Header{"Forwarded": {"for=1.2.3.4", "for=5.6.7.8"}}).
+		DelHeader("User-Agent", "Content-Type").
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "core", rq.Header.Get("X-Team"))
+			assert.Equal(t, "2", rq.Header.Get("X-Version"))
+			assert.Equal(t, []string{"application/json", "text/plain"}, rq.Header.Values("Accept"))
+			assert.Equal(t, []string{"1.1 proxy-a", "1.1 proxy-b"}, rq.Header.Values("Via"))
+			assert.Equal(t, []string{"for=1.2.3.4", "for=5.6.7.8"}, rq.Header.Values("Forwarded"))
+			assert.Empty(t, rq.Header.Values("User-Agent"))
+			assert.Empty(t, rq.Header.Values("Content-Type"))
+		})
+
+	New().GET("/").
+		AddHeader("X-Trace", "a").
+		SetHeaders(http.Header{"User-Agent": {"custom"}, "X-Trace": {"b"}}).
+		SetHeader(H{"x-team": "core"}).
+		DelHeader("X-Team").
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "custom", rq.Header.Get("User-Agent"))
+			assert.Equal(t, []string{"b"}, rq.Header.Values("X-Trace"))
+			assert.Empty(t, rq.Header.Values("X-Team"))
+		})
+}
+
+// TestCookieControl tests merged, repeated and raw request cookies
+func TestCookieControl(t *testing.T) {
+	New().GET("/").
+		SetCookie(H{"b": "1", "a": "1"}).
+		SetCookie(H{"b": "2"}).
+		AddCookies(&http.Cookie{Name: "dup", Value: "x"}, &http.Cookie{Name: "dup", Value: "y z"}).
+		SetRawCookie(`legacy="%E2%9C%93"; dup=raw`).
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, `a=1; b=2; dup=x; dup="y z"; legacy="%E2%9C%93"; dup=raw`, rq.Header.Get("Cookie"))
+			assert.Len(t, rq.Cookies(), 6)
+			assert.Nil(t, rq.TLS)
+		})
+}
+
+// TestSetSecureContext tests requests made as if over TLS
+func TestSetSecureContext(t *testing.T) {
+	New().GET("/").
+		SetSecureContext(true).
+		Run(http.HandlerFunc(rawBodyHandler), func(r HTTPResponse, rq HTTPRequest) {
+			require.NotNil(t, rq.TLS)
+			assert.True(t, rq.TLS.HandshakeComplete)
+		})
+
+	s := NewSession(sessionHandler())
+	s.New().POST("/login").SetForm(H{"user": "carol"}).SetSecureContext(true).
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {})
+
+	s.New().GET("/me").
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "session=carol;", r.Body.String())
+		})
+	s.New().GET("/me").SetSecureContext(true).
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "session=carol;secure=1;", r.Body.String())
+		})
+}
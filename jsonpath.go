@@ -0,0 +1,516 @@
+package gofight
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSON value types reported by JSONResult.Type.
+const (
+	JSONNull    = "null"
+	JSONBoolean = "boolean"
+	JSONNumber  = "number"
+	JSONString  = "string"
+	JSONArray   = "array"
+	JSONObject  = "object"
+)
+
+// JSONResult is the outcome of a JSONPath query on a response body.
+type JSONResult struct {
+	// Path is the queried expression.
+	Path string
+
+	value  any
+	exists bool
+	err    error
+}
+
+// errNoMatch is returned by JSONResult.Decode when the path matched nothing.
+var errNoMatch = errors.New("no value matched")
+
+// jsonSegment is one step of a parsed JSONPath expression. A GJSON
+// numeric member such as .0 has both a key and an index: it selects the
+// member of an object, or the element of an array.
+type jsonSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// JSONPath queries the JSON response body. The supported syntax is the
+// common subset of JSONPath and GJSON paths:
+//
+//	$.data.items[0].id     member and index access, the leading $ is optional
+//	$['odd key'][-1]       bracket notation and negative indexes
+//	$.data.items[*].id     wildcards over arrays and objects
+//	data.items.0.id        GJSON numeric members, indexing arrays
+//
+// A query with a wildcard yields an array holding every match. Other
+// syntax, such as recursive descent, filters, or the GJSON #, @ and |
+// operators, is reported by Err instead of matching nothing.
+func (r HTTPResponse) JSONPath(path string) JSONResult {
+	return queryJSON(r.Body.Bytes(), path)
+}
+
+// queryJSON evaluates path against the JSON document data.
+func queryJSON(data []byte, path string) JSONResult {
+	res := JSONResult{Path: path}
+
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		res.err = err
+		return res
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		res.err = fmt.Errorf("invalid JSON body: %w", err)
+		return res
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		res.err = errors.New("invalid JSON body: unexpected data after the document")
+		return res
+	}
+
+	nodes := []any{doc}
+	multi := false
+	for _, seg := range segments {
+		var next []any
+		for _, node := range nodes {
+			next = append(next, seg.apply(node)...)
+		}
+		nodes = next
+		multi = multi || seg.wildcard
+	}
+
+	switch {
+	case multi:
+		res.value, res.exists = nodes, len(nodes) > 0
+		if nodes == nil {
+			res.value = []any{}
+		}
+	case len(nodes) == 1:
+		res.value, res.exists = nodes[0], true
+	}
+
+	return res
+}
+
+// apply returns the children of node selected by the segment.
+func (seg jsonSegment) apply(node any) []any {
+	switch v := node.(type) {
+	case map[string]any:
+		if seg.wildcard {
+			out := make([]any, 0, len(v))
+			for _, k := range slices.Sorted(maps.Keys(v)) {
+				out = append(out, v[k])
+			}
+			return out
+		}
+		if seg.isIndex && seg.key == "" {
+			return nil
+		}
+		if child, ok := v[seg.key]; ok {
+			return []any{child}
+		}
+	case []any:
+		if seg.wildcard {
+			return v
+		}
+		if !seg.isIndex {
+			return nil
+		}
+		i := seg.index
+		if i < 0 {
+			i += len(v)
+		}
+		if i >= 0 && i < len(v) {
+			return []any{v[i]}
+		}
+	}
+
+	return nil
+}
+
+// jsonUnsupported lists the characters starting JSONPath filters and GJSON
+// queries, modifiers, pipes and pattern matches, which are rejected in
+// member names rather than matched literally.
+const jsonUnsupported = "#@|?*"
+
+// parseJSONPath splits a JSONPath expression into segments.
+func parseJSONPath(path string) ([]jsonSegment, error) {
+	p := strings.TrimPrefix(strings.TrimSpace(path), "$")
+
+	var segments []jsonSegment
+	for i := 0; i < len(p); {
+		switch {
+		case p[i] == '[':
+			end := jsonBracketEnd(p[i:])
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unclosed bracket", path)
+			}
+			seg, err := parseJSONBracket(strings.TrimSpace(p[i+1 : i+end]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath %q: %w", path, err)
+			}
+			segments = append(segments, seg)
+			i += end + 1
+		case p[i] == '.' || i == 0:
+			if strings.HasPrefix(p[i:], "..") {
+				return nil, fmt.Errorf("invalid JSONPath %q: recursive descent (..) is not supported", path)
+			}
+			if p[i] == '.' {
+				i++
+			}
+			if i < len(p) && p[i] == '*' {
+				segments = append(segments, jsonSegment{wildcard: true})
+				i++
+				continue
+			}
+			j := i
+			for j < len(p) && p[j] != '.' && p[j] != '[' {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty member name", path)
+			}
+			seg := jsonSegment{key: p[i:j]}
+			if k := strings.IndexAny(seg.key, jsonUnsupported); k >= 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: %q is not supported, quote member names using it as in ['%s']",
+					path, seg.key[k], seg.key)
+			}
+			if isDigits(seg.key) {
+				if index, err := strconv.Atoi(seg.key); err == nil {
+					seg.index, seg.isIndex = index, true
+				}
+			}
+			segments = append(segments, seg)
+			i = j
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", path, p[i])
+		}
+	}
+
+	return segments, nil
+}
+
+// jsonBracketEnd returns the offset of the ] closing the bracket p starts
+// with, skipping a quoted member name, or -1.
+func jsonBracketEnd(p string) int {
+	i := 1
+	for i < len(p) && p[i] == ' ' {
+		i++
+	}
+	if i < len(p) && (p[i] == '\'' || p[i] == '"') {
+		closing := strings.IndexByte(p[i+1:], p[i])
+		if closing < 0 {
+			return -1
+		}
+		i += closing + 2
+	}
+
+	end := strings.IndexByte(p[i:], ']')
+	if end < 0 {
+		return -1
+	}
+
+	return i + end
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits.
+func isDigits(s string) bool {
+	for i := range len(s) {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// parseJSONBracket parses the content of a [...] segment.
+func parseJSONBracket(inner string) (jsonSegment, error) {
+	if inner == "*" {
+		return jsonSegment{wildcard: true}, nil
+	}
+
+	if n := len(inner); n >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[n-1] == inner[0] {
+		return jsonSegment{key: inner[1 : n-1]}, nil
+	}
+
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return jsonSegment{}, fmt.Errorf("invalid index %q", inner)
+	}
+
+	return jsonSegment{index: index, isIndex: true}, nil
+}
+
+// Exists reports whether the path matched a value. A JSON null counts as
+// an existing value.
+func (r JSONResult) Exists() bool {
+	return r.exists
+}
+
+// Err returns the error that prevented the query from running, such as a
+// malformed path or a body that is not JSON.
+func (r JSONResult) Err() error {
+	return r.err
+}
+
+// Value returns the matched value as decoded by encoding/json, with numbers
+// kept as json.Number. It is nil when nothing matched.
+func (r JSONResult) Value() any {
+	return r.value
+}
+
+// Type returns the JSON type of the matched value, one of the JSON*
+// constants, or "" when nothing matched.
+func (r JSONResult) Type() string {
+	if !r.exists {
+		return ""
+	}
+
+	return jsonType(r.value)
+}
+
+// String returns the matched string, or the JSON text of any other value.
+func (r JSONResult) String() string {
+	switch v := r.value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// Int returns the matched number as an int64, or 0 for other types.
+func (r JSONResult) Int() int64 {
+	n, ok := r.value.(json.Number)
+	if !ok {
+		return 0
+	}
+
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+
+	f, _ := n.Float64()
+
+	return int64(f)
+}
+
+// Float returns the matched number as a float64, or 0 for other types.
+func (r JSONResult) Float() float64 {
+	n, ok := r.value.(json.Number)
+	if !ok {
+		return 0
+	}
+
+	f, _ := n.Float64()
+
+	return f
+}
+
+// Bool returns the matched boolean, or false for other types.
+func (r JSONResult) Bool() bool {
+	b, _ := r.value.(bool)
+
+	return b
+}
+
+// Array returns the matched array, or nil for other types.
+func (r JSONResult) Array() []any {
+	a, _ := r.value.([]any)
+
+	return a
+}
+
+// Map returns the matched object, or nil for other types.
+func (r JSONResult) Map() map[string]any {
+	m, _ := r.value.(map[string]any)
+
+	return m
+}
+
+// Len returns the number of elements of an array, members of an object or
+// characters of a string, and -1 for any other value.
+func (r JSONResult) Len() int {
+	switch v := r.value.(type) {
+	case []any:
+		return len(v)
+	case map[string]any:
+		return len(v)
+	case string:
+		return utf8.RuneCountInString(v)
+	default:
+		return -1
+	}
+}
+
+// Decode unmarshals the matched value into v.
+func (r JSONResult) Decode(v any) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	if !r.exists {
+		return fmt.Errorf("JSONPath %s: %w", r.Path, errNoMatch)
+	}
+
+	b, err := json.Marshal(r.value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// Equal reports whether the matched value is JSON-equal to want, which may
+// be any value that marshals to JSON.
+func (r JSONResult) Equal(want any) bool {
+	if !r.exists {
+		return false
+	}
+
+	return jsonEqual(r.value, want)
+}
+
+// jsonType returns the JSON type name of a decoded value.
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return JSONNull
+	case bool:
+		return JSONBoolean
+	case json.Number, canonicalNumber, float64:
+		return JSONNumber
+	case string:
+		return JSONString
+	case []any:
+		return JSONArray
+	case map[string]any:
+		return JSONObject
+	default:
+		return ""
+	}
+}
+
+// jsonEqual compares two values after a JSON round trip.
+func jsonEqual(got, want any) bool {
+	a, err := roundTripJSON(got)
+	if err != nil {
+		return false
+	}
+
+	b, err := roundTripJSON(want)
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// roundTripJSON marshals v and decodes it back into its normalized form.
+func roundTripJSON(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeJSON(b)
+}
+
+// jsonPathResult runs a query for an expectation, recording a failure when
+// the query itself cannot run.
+func (e *Expectation) jsonPathResult(path string) (JSONResult, bool) {
+	res := e.resp.JSONPath(path)
+	if err := res.Err(); err != nil {
+		e.fail("JSONPath %s: %v", path, err)
+		return res, false
+	}
+
+	return res, true
+}
+
+// JSONPathExists expects the path to match a value in the JSON body.
+func (e *Expectation) JSONPathExists(path string) *Expectation {
+	if res, ok := e.jsonPathResult(path); ok && !res.Exists() {
+		e.fail("expected JSONPath %s to exist", path)
+	}
+
+	return e
+}
+
+// JSONPathMissing expects the path not to match anything in the JSON body.
+func (e *Expectation) JSONPathMissing(path string) *Expectation {
+	if res, ok := e.jsonPathResult(path); ok && res.Exists() {
+		e.fail("expected JSONPath %s to be missing, got %s", path, res.String())
+	}
+
+	return e
+}
+
+// JSONPathEqual expects the value at path to be JSON-equal to want.
+func (e *Expectation) JSONPathEqual(path string, want any) *Expectation {
+	res, ok := e.jsonPathResult(path)
+	switch {
+	case !ok:
+	case !res.Exists():
+		e.fail("expected JSONPath %s to equal %v, but it is missing", path, want)
+	case !res.Equal(want):
+		b, _ := json.Marshal(want)
+		e.fail("expected JSONPath %s to equal %s, got %s", path, b, res.String())
+	}
+
+	return e
+}
+
+// JSONPathType expects the value at path to have the JSON type typ, one of
+// the JSON* constants.
+func (e *Expectation) JSONPathType(path, typ string) *Expectation {
+	res, ok := e.jsonPathResult(path)
+	switch {
+	case !ok:
+	case !res.Exists():
+		e.fail("expected JSONPath %s to be %s, but it is missing", path, typ)
+	case res.Type() != typ:
+		e.fail("expected JSONPath %s to be %s, got %s", path, typ, res.Type())
+	}
+
+	return e
+}
+
+// JSONPathLen expects the array, object or string at path to have length n.
+func (e *Expectation) JSONPathLen(path string, n int) *Expectation {
+	res, ok := e.jsonPathResult(path)
+	switch {
+	case !ok:
+	case !res.Exists():
+		e.fail("expected JSONPath %s to have length %d, but it is missing", path, n)
+	case res.Len() < 0:
+		e.fail("expected JSONPath %s to have length %d, got %s", path, n, res.Type())
+	case res.Len() != n:
+		e.fail("expected JSONPath %s to have length %d, got %d", path, n, res.Len())
+	}
+
+	return e
+}
@@ -0,0 +1,177 @@
+package gofight
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const nestedJSON = `{
+  "data": {
+    "total": 2,
+    "ratio": 0.5,
+    "active": true,
+    "owner": null,
+    "items": [
+      {"id": 9007199254740993, "name": "first", "tags": ["a", "b"]},
+      {"id": 2, "name": "second", "tags": []}
+    ],
+    "odd key": "odd"
+  }
+}`
+
+func nestedJSONHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, nestedJSON)
+}
+
+// TestJSONPath tests querying values from the response body
+func TestJSONPath(t *testing.T) {
+	New().GET("/").
+		Run(http.HandlerFunc(nestedJSONHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, int64(9007199254740993), r.JSONPath("$.data.items[0].id").Int())
+			assert.Equal(t, "second", r.JSONPath("data.items[1].name").String())
+			assert.Equal(t, "second", r.JSONPath("$.data.items[-1].name").String())
+			assert.Equal(t, "odd", r.JSONPath(`$.data['odd key']`).String())
+			assert.Equal(t, "odd", r.JSONPath(`$["data"]["odd key"]`).String())
+			assert.Equal(t, 0.5, r.JSONPath("$.data.ratio").Float())
+			assert.True(t, r.JSONPath("$.data.active").Bool())
+			assert.Equal(t, 2, r.JSONPath("$.data.items").Len())
+			assert.Equal(t, 2, r.JSONPath("$.data.items[0].tags").Len())
+			assert.Equal(t, 5, r.JSONPath("$.data.items[0].name").Len())
+			assert.Len(t, r.JSONPath("$.data").Map(), 6)
+			assert.Equal(t, `["a","b"]`, r.JSONPath("$.data.items[0].tags").String())
+
+			owner := r.JSONPath("$.data.owner")
+			assert.True(t, owner.Exists())
+			assert.Equal(t, JSONNull, owner.Type())
+
+			missing := r.JSONPath("$.data.items[5].id")
+			assert.False(t, missing.Exists())
+			assert.NoError(t, missing.Err())
+			assert.Equal(t, "", missing.Type())
+			assert.Error(t, missing.Decode(new(int)))
+
+			names := r.JSONPath("$.data.items[*].name")
+			assert.True(t, names.Exists())
+			assert.Equal(t, JSONArray, names.Type())
+			assert.True(t, names.Equal([]string{"first", "second"}))
+
+			var tags []string
+			require.NoError(t, r.JSONPath("$.data.items[0].tags").Decode(&tags))
+			assert.Equal(t, []string{"a", "b"}, tags)
+
+			assert.Error(t, r.JSONPath("$.data.items[x]").Err())
+			assert.Error(t, r.JSONPath("$.data.items[0").Err())
+			assert.Error(t, r.JSONPath("$.data..items").Err())
+		})
+}
+
+// TestJSONPathSyntax tests GJSON numeric members and quoted brackets
+func TestJSONPathSyntax(t *testing.T) {
+	doc := []byte(`{"items": [{"id": 1}, {"id": 2}], "map": {"0": "zero"}, "a]b": "bracket", "a'b": "quote"}`)
+
+	assert.Equal(t, "1", queryJSON(doc, "items.0.id").String())
+	assert.Equal(t, "2", queryJSON(doc, "$.items.1.id").String())
+	assert.Equal(t, "zero", queryJSON(doc, "map.0").String())
+	assert.False(t, queryJSON(doc, "items.2.id").Exists())
+	assert.Equal(t, "bracket", queryJSON(doc, `$['a]b']`).String())
+	assert.Equal(t, "quote", queryJSON(doc, `$[ "a'b" ]`).String())
+	assert.Error(t, queryJSON(doc, `$['a]b`).Err())
+
+	for path, want := range map[string]string{
+		"items.#":           `'#' is not supported`,
+		"items.#.id":        `'#' is not supported`,
+		`items.#(id==1).id`: `'#' is not supported`,
+		"items|@reverse":    `'|' is not supported`,
+		"@this":             `'@' is not supported`,
+		"it?ms.0":           `'?' is not supported`,
+		"ite*":              `'*' is not supported`,
+		"$..id":             "recursive descent (..) is not supported",
+		"items..id":         "recursive descent (..) is not supported",
+		"$.items[?(@.id)]":  `invalid index "?(@.id)"`,
+	} {
+		assert.ErrorContains(t, queryJSON(doc, path).Err(), want, path)
+	}
+	assert.Equal(t, "hash", queryJSON([]byte(`{"a#b": "hash"}`), `$['a#b']`).String())
+
+	res := queryJSON([]byte(`{"id": 1} {"id": 2}`), "id")
+	assert.EqualError(t, res.Err(), "invalid JSON body: unexpected data after the document")
+	assert.False(t, res.Exists())
+	assert.NoError(t, queryJSON([]byte("{\"id\": 1}\n"), "id").Err())
+}
+
+// TestJSONPathTypes tests the reported JSON types
+func TestJSONPathTypes(t *testing.T) {
+	New().GET("/").
+		Run(http.HandlerFunc(nestedJSONHandler), func(r HTTPResponse, rq HTTPRequest) {
+			tests := map[string]string{
+				"$":                      JSONObject,
+				"$.data.total":           JSONNumber,
+				"$.data.active":          JSONBoolean,
+				"$.data.items":           JSONArray,
+				"$.data.items[0].name":   JSONString,
+				"$.data.items[*].tags":   JSONArray,
+				"$.data.items[0].tags.*": JSONArray,
+			}
+			for path, typ := range tests {
+				assert.Equal(t, typ, r.JSONPath(path).Type(), path)
+			}
+		})
+}
+
+// TestJSONPathInvalidBody tests querying a body that is not JSON
+func TestJSONPathInvalidBody(t *testing.T) {
+	New().GET("/").
+		Run(basicEngine(), func(r HTTPResponse, rq HTTPRequest) {
+			res := r.JSONPath("$.data")
+			assert.Error(t, res.Err())
+			assert.False(t, res.Exists())
+		})
+}
+
+// TestExpectJSONPath tests the JSONPath expectations
+func TestExpectJSONPath(t *testing.T) {
+	tb := &recordingTB{TB: t}
+
+	NewT(tb).GET("/").
+		Run(http.HandlerFunc(nestedJSONHandler), func(r HTTPResponse, rq HTTPRequest) {
+			r.Expect().
+				JSONPathExists("$.data.owner").
+				JSONPathMissing("$.data.deleted").
+				JSONPathEqual("$.data.total", 2.0).
+				JSONPathEqual("$.data.items[0].tags", []string{"a", "b"}).
+				JSONPathEqual("$.data.items[*].id", []any{9007199254740993, 2}).
+				JSONPathType("$.data.items", JSONArray).
+				JSONPathLen("$.data.items[1].tags", 0)
+		})
+	assert.Empty(t, tb.errors)
+
+	NewT(tb).GET("/").
+		Run(http.HandlerFunc(nestedJSONHandler), func(r HTTPResponse, rq HTTPRequest) {
+			r.Expect().
+				JSONPathExists("$.data.deleted").
+				JSONPathMissing("$.data.total").
+				JSONPathEqual("$.data.total", "2").
+				JSONPathEqual("$.data.deleted", 1).
+				JSONPathType("$.data.total", JSONString).
+				JSONPathLen("$.data.total", 1).
+				JSONPathLen("$.data.items", 3).
+				JSONPathExists("$.data[")
+		})
+
+	require.Len(t, tb.errors, 1)
+	msg := tb.errors[0]
+	assert.Contains(t, msg, "8 expectation(s) failed")
+	assert.Contains(t, msg, "expected JSONPath $.data.deleted to exist")
+	assert.Contains(t, msg, "expected JSONPath $.data.total to be missing, got 2")
+	assert.Contains(t, msg, `expected JSONPath $.data.total to equal "2", got 2`)
+	assert.Contains(t, msg, "expected JSONPath $.data.deleted to equal 1, but it is missing")
+	assert.Contains(t, msg, "expected JSONPath $.data.total to be string, got number")
+	assert.Contains(t, msg, "expected JSONPath $.data.total to have length 1, got number")
+	assert.Contains(t, msg, "expected JSONPath $.data.items to have length 3, got 2")
+	assert.Contains(t, msg, "unclosed bracket")
+}
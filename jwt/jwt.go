@@ -0,0 +1,398 @@
+// Package jwt mints JSON Web Tokens and serves the matching JWKS document
+// for tests of authentication middleware, fully in-process.
+//
+//	key, _ := jwt.GenerateKey(jwt.RS256, "key-1")
+//	jwks := httptest.NewServer(jwt.JWKSHandler(key))
+//	defer jwks.Close()
+//
+//	gofight.New().GET("/me").
+//	  SetBearerToken(key.MustSign(jwt.Claims{"sub": "alice"}, jwt.ExpiresIn(time.Hour))).
+//	  Run(handler, ...)
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA-256 for crypto.Hash
+	_ "crypto/sha512" // register SHA-384 and SHA-512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/appleboy/gofight/v2"
+)
+
+// Algorithm is a JWS signing algorithm.
+type Algorithm string
+
+// Supported algorithms.
+const (
+	HS256 Algorithm = "HS256"
+	HS384 Algorithm = "HS384"
+	HS512 Algorithm = "HS512"
+	RS256 Algorithm = "RS256"
+	RS384 Algorithm = "RS384"
+	RS512 Algorithm = "RS512"
+	PS256 Algorithm = "PS256"
+	PS384 Algorithm = "PS384"
+	PS512 Algorithm = "PS512"
+	ES256 Algorithm = "ES256"
+	ES384 Algorithm = "ES384"
+	ES512 Algorithm = "ES512"
+)
+
+// rsaKeyBits is the size of generated RSA keys.
+const rsaKeyBits = 2048
+
+// Claims is the payload of a token.
+type Claims map[string]any
+
+// Key signs tokens with an algorithm and a key ID.
+type Key struct {
+	// ID is sent as the "kid" header and in the JWKS document.
+	ID string
+	// Algorithm is the signing algorithm.
+	Algorithm Algorithm
+
+	signer crypto.Signer
+	secret []byte
+}
+
+// GenerateKey generates a random key for alg: a 2048-bit RSA key for the
+// RS and PS algorithms, a key on the matching curve for the ES algorithms,
+// and a secret as long as the hash for the HS algorithms.
+func GenerateKey(alg Algorithm, kid string) (*Key, error) {
+	k := &Key{ID: kid, Algorithm: alg}
+
+	var err error
+	switch alg {
+	case HS256, HS384, HS512:
+		k.secret = make([]byte, hashOf(alg).Size())
+		_, err = rand.Read(k.secret)
+	case RS256, RS384, RS512, PS256, PS384, PS512:
+		k.signer, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case ES256, ES384, ES512:
+		k.signer, err = ecdsa.GenerateKey(curveOf(alg), rand.Reader)
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwt: failed to generate %s key: %w", alg, err)
+	}
+
+	return k, nil
+}
+
+// MustGenerateKey is like GenerateKey but panics on error.
+func MustGenerateKey(alg Algorithm, kid string) *Key {
+	k, err := GenerateKey(alg, kid)
+	if err != nil {
+		panic(err)
+	}
+
+	return k
+}
+
+// NewHMACKey creates an HS256, HS384 or HS512 key from a shared secret.
+func NewHMACKey(alg Algorithm, kid string, secret []byte) (*Key, error) {
+	switch alg {
+	case HS256, HS384, HS512:
+		return &Key{ID: kid, Algorithm: alg, secret: secret}, nil
+	default:
+		return nil, fmt.Errorf("jwt: %q is not an HMAC algorithm", alg)
+	}
+}
+
+// NewKey wraps an existing *rsa.PrivateKey or *ecdsa.PrivateKey.
+func NewKey(alg Algorithm, kid string, key crypto.Signer) (*Key, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		switch alg {
+		case RS256, RS384, RS512, PS256, PS384, PS512:
+			return &Key{ID: kid, Algorithm: alg, signer: key}, nil
+		}
+	case *ecdsa.PrivateKey:
+		if curve := curveOf(alg); curve != nil && key.Curve == curve {
+			return &Key{ID: kid, Algorithm: alg, signer: key}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("jwt: %T cannot be used with %q", key, alg)
+}
+
+// Secret returns the shared secret of an HMAC key, nil otherwise.
+func (k *Key) Secret() []byte {
+	return k.secret
+}
+
+// Public returns the public key of an RSA or ECDSA key, nil otherwise.
+func (k *Key) Public() crypto.PublicKey {
+	if k.signer == nil {
+		return nil
+	}
+
+	return k.signer.Public()
+}
+
+// tokenConfig holds the options of Sign.
+type tokenConfig struct {
+	header map[string]any
+	claims Claims
+}
+
+// Option customizes a token.
+type Option func(*tokenConfig)
+
+// ExpiresIn sets the "exp" claim to now plus d; a negative d mints an
+// expired token.
+func ExpiresIn(d time.Duration) Option {
+	return ExpiresAt(time.Now().Add(d))
+}
+
+// ExpiresAt sets the "exp" claim.
+func ExpiresAt(t time.Time) Option {
+	return func(c *tokenConfig) {
+		c.claims["exp"] = t.Unix()
+	}
+}
+
+// IssuedAt sets the "iat" claim.
+func IssuedAt(t time.Time) Option {
+	return func(c *tokenConfig) {
+		c.claims["iat"] = t.Unix()
+	}
+}
+
+// NotBefore sets the "nbf" claim.
+func NotBefore(t time.Time) Option {
+	return func(c *tokenConfig) {
+		c.claims["nbf"] = t.Unix()
+	}
+}
+
+// KeyID overrides the "kid" header, e.g. to test unknown keys; an empty kid
+// removes the header.
+func KeyID(kid string) Option {
+	return func(c *tokenConfig) {
+		if kid == "" {
+			delete(c.header, "kid")
+			return
+		}
+		c.header["kid"] = kid
+	}
+}
+
+// HeaderParam sets a JOSE header parameter.
+func HeaderParam(name string, value any) Option {
+	return func(c *tokenConfig) {
+		c.header[name] = value
+	}
+}
+
+// Sign mints a token with the given claims.
+func (k *Key) Sign(claims Claims, opts ...Option) (string, error) {
+	cfg := &tokenConfig{
+		header: map[string]any{"alg": string(k.Algorithm), "typ": "JWT"},
+		claims: maps.Clone(claims),
+	}
+	if cfg.claims == nil {
+		cfg.claims = Claims{}
+	}
+	if k.ID != "" {
+		cfg.header["kid"] = k.ID
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	header, err := json.Marshal(cfg.header)
+	if err != nil {
+		return "", fmt.Errorf("jwt: failed to encode header: %w", err)
+	}
+	payload, err := json.Marshal(cfg.claims)
+	if err != nil {
+		return "", fmt.Errorf("jwt: failed to encode claims: %w", err)
+	}
+
+	input := encode(header) + "." + encode(payload)
+	signature, err := k.sign([]byte(input))
+	if err != nil {
+		return "", fmt.Errorf("jwt: failed to sign: %w", err)
+	}
+
+	return input + "." + encode(signature), nil
+}
+
+// MustSign is like Sign but panics on error.
+func (k *Key) MustSign(claims Claims, opts ...Option) string {
+	token, err := k.Sign(claims, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return token
+}
+
+// Authorize mints a token and sends it as a bearer token with rc. It panics
+// if the token cannot be signed.
+//
+//	key.Authorize(gofight.New().GET("/me"), jwt.Claims{"sub": "alice"}).
+//	  Run(handler, ...)
+func (k *Key) Authorize(rc *gofight.RequestConfig, claims Claims, opts ...Option) *gofight.RequestConfig {
+	return rc.SetBearerToken(k.MustSign(claims, opts...))
+}
+
+// sign signs input with the key.
+func (k *Key) sign(input []byte) ([]byte, error) {
+	hash := hashOf(k.Algorithm)
+	if hash == 0 {
+		return nil, fmt.Errorf("unsupported algorithm %q", k.Algorithm)
+	}
+
+	if k.secret != nil {
+		mac := hmac.New(hash.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	}
+	if k.signer == nil {
+		return nil, errors.New("key has no private key")
+	}
+
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch key := k.signer.(type) {
+	case *rsa.PrivateKey:
+		switch k.Algorithm {
+		case PS256, PS384, PS512:
+			return rsa.SignPSS(rand.Reader, key, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		default:
+			return rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// JWK returns the public JSON Web Key of an RSA or ECDSA key. HMAC keys have
+// no public part and return nil.
+func (k *Key) JWK() map[string]any {
+	jwk := map[string]any{
+		"kid": k.ID,
+		"alg": string(k.Algorithm),
+		"use": "sig",
+	}
+
+	switch pub := k.Public().(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = encode(pub.N.Bytes())
+		jwk["e"] = encode(exponentBytes(pub.E))
+	case *ecdsa.PublicKey:
+		point, err := pub.ECDH()
+		if err != nil {
+			return nil
+		}
+		// Uncompressed point: 0x04 || X || Y.
+		b := point.Bytes()[1:]
+		jwk["kty"] = "EC"
+		jwk["crv"] = pub.Curve.Params().Name
+		jwk["x"] = encode(b[:len(b)/2])
+		jwk["y"] = encode(b[len(b)/2:])
+	default:
+		return nil
+	}
+
+	return jwk
+}
+
+// JWKS returns the JSON Web Key Set document of the public keys.
+func JWKS(keys ...*Key) []byte {
+	set := struct {
+		Keys []map[string]any `json:"keys"`
+	}{Keys: []map[string]any{}}
+	for _, k := range keys {
+		if jwk := k.JWK(); jwk != nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	// A map of strings always encodes.
+	b, _ := json.Marshal(set)
+
+	return b
+}
+
+// JWKSHandler serves the JWKS document of keys, as fetched by middleware
+// from a jwks_uri. Serve it with httptest.NewServer, or route the JWKS URL
+// to it in the client used by the middleware.
+func JWKSHandler(keys ...*Key) http.Handler {
+	doc := JWKS(keys...)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(gofight.ContentType, "application/jwk-set+json")
+		_, _ = w.Write(doc)
+	})
+}
+
+// encode returns the unpadded base64url encoding of b.
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// exponentBytes returns the minimal big-endian bytes of an RSA exponent.
+func exponentBytes(e int) []byte {
+	var b []byte
+	for ; e > 0; e >>= 8 {
+		b = append([]byte{byte(e)}, b...)
+	}
+
+	return b
+}
+
+// hashOf returns the hash of alg.
+func hashOf(alg Algorithm) crypto.Hash {
+	switch alg {
+	case HS256, RS256, PS256, ES256:
+		return crypto.SHA256
+	case HS384, RS384, PS384, ES384:
+		return crypto.SHA384
+	case HS512, RS512, PS512, ES512:
+		return crypto.SHA512
+	default:
+		return 0
+	}
+}
+
+// curveOf returns the curve of an ES algorithm.
+func curveOf(alg Algorithm) elliptic.Curve {
+	switch alg {
+	case ES256:
+		return elliptic.P256()
+	case ES384:
+		return elliptic.P384()
+	case ES512:
+		return elliptic.P521()
+	default:
+		return nil
+	}
+}
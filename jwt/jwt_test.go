@@ -0,0 +1,185 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appleboy/gofight/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publicKeyFromJWK rebuilds a public key from its JWK, as middleware would
+func publicKeyFromJWK(t *testing.T, jwk map[string]any) crypto.PublicKey {
+	t.Helper()
+
+	decode := func(name string) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(jwk[name].(string))
+		require.NoError(t, err)
+		return new(big.Int).SetBytes(b)
+	}
+
+	switch jwk["kty"] {
+	case "RSA":
+		return &rsa.PublicKey{N: decode("n"), E: int(decode("e").Int64())}
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		return &ecdsa.PublicKey{Curve: curves[jwk["crv"].(string)], X: decode("x"), Y: decode("y")} //nolint:staticcheck
+	default:
+		t.Fatalf("unexpected kty %v", jwk["kty"])
+		return nil
+	}
+}
+
+// verify checks the signature of token and returns its header and claims
+func verify(t *testing.T, token string, k *Key, pub crypto.PublicKey) (map[string]any, map[string]any) {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	var header, claims map[string]any
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &header))
+	b, err = base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &claims))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+
+	alg := Algorithm(header["alg"].(string))
+	hash := hashOf(alg)
+	input := []byte(parts[0] + "." + parts[1])
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch pub := pub.(type) {
+	case nil:
+		mac := hmac.New(hash.New, k.Secret())
+		mac.Write(input)
+		err = nil
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			err = errors.New("invalid HMAC")
+		}
+	case *rsa.PublicKey:
+		if strings.HasPrefix(string(alg), "PS") {
+			err = rsa.VerifyPSS(pub, hash, digest, signature, nil)
+		} else {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		}
+	case *ecdsa.PublicKey:
+		size := len(signature) / 2
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			err = errors.New("invalid ECDSA signature")
+		}
+	}
+	require.NoError(t, err, alg)
+
+	return header, claims
+}
+
+// TestSign tests tokens signed with every algorithm
+func TestSign(t *testing.T) {
+	for _, alg := range []Algorithm{HS256, HS384, HS512, RS256, PS256, ES256, ES384, ES512} {
+		t.Run(string(alg), func(t *testing.T) {
+			k := MustGenerateKey(alg, "kid-"+string(alg))
+
+			var pub crypto.PublicKey
+			if jwk := k.JWK(); jwk != nil {
+				assert.Equal(t, k.ID, jwk["kid"])
+				assert.Equal(t, string(alg), jwk["alg"])
+				pub = publicKeyFromJWK(t, jwk)
+			}
+
+			exp := time.Now().Add(time.Hour).Truncate(time.Second)
+			token := k.MustSign(Claims{"sub": "alice", "roles": []string{"admin"}}, ExpiresAt(exp))
+			header, claims := verify(t, token, k, pub)
+
+			assert.Equal(t, map[string]any{"alg": string(alg), "typ": "JWT", "kid": k.ID}, header)
+			assert.Equal(t, "alice", claims["sub"])
+			assert.Equal(t, []any{"admin"}, claims["roles"])
+			assert.InDelta(t, float64(exp.Unix()), claims["exp"], 0)
+		})
+	}
+}
+
+// TestSignOptions tests header and registered claim options
+func TestSignOptions(t *testing.T) {
+	k, err := NewHMACKey(HS256, "shared", []byte("secret"))
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	header, claims := verify(t, k.MustSign(nil,
+		IssuedAt(now), NotBefore(now), ExpiresIn(-time.Minute),
+		KeyID(""), HeaderParam("cty", "JWT")), k, nil)
+
+	assert.Equal(t, map[string]any{"alg": "HS256", "typ": "JWT", "cty": "JWT"}, header)
+	assert.InDelta(t, 1700000000, claims["iat"], 0)
+	assert.InDelta(t, 1700000000, claims["nbf"], 0)
+	assert.Less(t, claims["exp"], float64(time.Now().Unix()))
+
+	_, err = NewHMACKey(RS256, "", nil)
+	assert.Error(t, err)
+	_, err = GenerateKey("none", "")
+	assert.Error(t, err)
+	_, err = NewKey(ES384, "", MustGenerateKey(ES256, "").signer)
+	assert.Error(t, err)
+}
+
+// TestJWKSHandler tests the JWKS document and a middleware using it
+func TestJWKSHandler(t *testing.T) {
+	rsaKey := MustGenerateKey(RS256, "rsa")
+	ecKey := MustGenerateKey(ES256, "ec")
+	hmacKey := MustGenerateKey(HS256, "hmac")
+
+	jwks := httptest.NewServer(JWKSHandler(rsaKey, ecKey, hmacKey))
+	defer jwks.Close()
+
+	// middleware fetches the keys from the JWKS URL and checks the token.
+	middleware := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, jwks.URL, nil)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, "application/jwk-set+json", resp.Header.Get("Content-Type"))
+
+		var set struct {
+			Keys []map[string]any `json:"keys"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&set))
+		require.Len(t, set.Keys, 2)
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		for _, jwk := range set.Keys {
+			if jwk["kid"] == ecKey.ID {
+				_, claims := verify(t, token, ecKey, publicKeyFromJWK(t, jwk))
+				_, _ = io.WriteString(w, "hello "+claims["sub"].(string))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	ecKey.Authorize(gofight.New().GET("/me"), Claims{"sub": "alice"}, ExpiresIn(time.Hour)).
+		Run(middleware, func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+			assert.Equal(t, "hello alice", r.Body.String())
+		})
+}
@@ -0,0 +1,353 @@
+package gofight
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Multipart subtypes supported by the builder.
+const (
+	MultipartFormData = "form-data"
+	MultipartRelated  = "related"
+	MultipartMixed    = "mixed"
+)
+
+// Multipart builds a multipart request body part by part. Parts are written
+// in the order they were added, each with its own headers, so fields are
+// deterministic and several files may share a field name.
+//
+//	m := gofight.NewMultipart().
+//	  Field("title", "report").
+//	  Field("meta", `{"public":true}`, gofight.PartContentType("application/json")).
+//	  FileFromPath("files", "./testdata/hello.txt").
+//	  FileFromPath("files", "./testdata/world.txt")
+//
+//	r.POST("/upload").SetMultipart(m)
+type Multipart struct {
+	subtype  string
+	params   map[string]string
+	boundary string
+	parts    []multipartPart
+}
+
+// multipartPart is one part of a Multipart body. open is called each time
+// the body is written.
+type multipartPart struct {
+	header textproto.MIMEHeader
+	name   string
+	open   func() (io.Reader, func() error, error)
+}
+
+// PartOption customizes the headers of a single part.
+type PartOption func(textproto.MIMEHeader)
+
+// PartContentType sets the Content-Type of a part.
+func PartContentType(contentType string) PartOption {
+	return func(h textproto.MIMEHeader) {
+		h.Set(ContentType, contentType)
+	}
+}
+
+// PartHeader adds a header to a part.
+func PartHeader(key, value string) PartOption {
+	return func(h textproto.MIMEHeader) {
+		h.Add(key, value)
+	}
+}
+
+// NewMultipart creates a multipart/form-data builder.
+func NewMultipart() *Multipart {
+	return &Multipart{subtype: MultipartFormData, params: map[string]string{}}
+}
+
+// NewMultipartRelated creates a multipart/related builder, e.g. for APIs
+// that upload JSON metadata followed by binary content. rootType is the
+// media type of the root part, sent as the "type" parameter.
+func NewMultipartRelated(rootType string) *Multipart {
+	m := &Multipart{subtype: MultipartRelated, params: map[string]string{}}
+	if rootType != "" {
+		m.params["type"] = rootType
+	}
+
+	return m
+}
+
+// NewMultipartMixed creates a multipart/mixed builder.
+func NewMultipartMixed() *Multipart {
+	return &Multipart{subtype: MultipartMixed, params: map[string]string{}}
+}
+
+// SetBoundary sets a fixed boundary, which keeps bodies stable across runs.
+func (m *Multipart) SetBoundary(boundary string) *Multipart {
+	m.boundary = boundary
+
+	return m
+}
+
+// SetParam sets a parameter of the multipart Content-Type, such as "start"
+// for multipart/related.
+func (m *Multipart) SetParam(key, value string) *Multipart {
+	m.params[key] = value
+
+	return m
+}
+
+// Field adds a text field.
+func (m *Multipart) Field(name, value string, opts ...PartOption) *Multipart {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": name}))
+
+	return m.add(h, name, func() (io.Reader, func() error, error) {
+		return strings.NewReader(value), nil, nil
+	}, opts)
+}
+
+// File adds a file read from r. The Content-Type defaults to the type
+// registered for the file name extension, or application/octet-stream.
+// r is consumed when the body is written, so the request can only run once.
+func (m *Multipart) File(field, filename string, r io.Reader, opts ...PartOption) *Multipart {
+	return m.add(fileHeader(field, filename), filename, func() (io.Reader, func() error, error) {
+		return r, nil, nil
+	}, opts)
+}
+
+// FileBytes adds a file with the given content.
+func (m *Multipart) FileBytes(field, filename string, content []byte, opts ...PartOption) *Multipart {
+	return m.add(fileHeader(field, filename), filename, func() (io.Reader, func() error, error) {
+		return bytes.NewReader(content), nil, nil
+	}, opts)
+}
+
+// FileFromPath adds the file stored at path on disk. The file is opened
+// when the body is written.
+func (m *Multipart) FileFromPath(field, path string, opts ...PartOption) *Multipart {
+	return m.add(fileHeader(field, filepath.Base(path)), path, func() (io.Reader, func() error, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, f.Close, nil
+	}, opts)
+}
+
+// FileFromFS adds the file name read from fsys, such as an embed.FS.
+func (m *Multipart) FileFromFS(field string, fsys fs.FS, name string, opts ...PartOption) *Multipart {
+	return m.add(fileHeader(field, path.Base(name)), name, func() (io.Reader, func() error, error) {
+		f, err := fsys.Open(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, f.Close, nil
+	}, opts)
+}
+
+// Part adds a part with arbitrary headers, as used by multipart/related and
+// multipart/mixed bodies.
+func (m *Multipart) Part(header textproto.MIMEHeader, r io.Reader) *Multipart {
+	h := make(textproto.MIMEHeader, len(header))
+	for k, v := range header {
+		h[textproto.CanonicalMIMEHeaderKey(k)] = append([]string(nil), v...)
+	}
+
+	return m.add(h, "part", func() (io.Reader, func() error, error) {
+		return r, nil, nil
+	}, nil)
+}
+
+// add appends a part after applying its options.
+func (m *Multipart) add(h textproto.MIMEHeader, name string, open func() (io.Reader, func() error, error), opts []PartOption) *Multipart {
+	for _, opt := range opts {
+		opt(h)
+	}
+	m.parts = append(m.parts, multipartPart{header: h, name: name, open: open})
+
+	return m
+}
+
+// fileHeader builds the headers of a form-data file part.
+func fileHeader(field, filename string) textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+		"name":     field,
+		"filename": filename,
+	}))
+
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = ApplicationOctetStream
+	}
+	h.Set(ContentType, contentType)
+
+	return h
+}
+
+// newWriter creates the multipart.Writer for w.
+func (m *Multipart) newWriter(w io.Writer) (*multipart.Writer, error) {
+	mw := multipart.NewWriter(w)
+	if m.boundary != "" {
+		if err := mw.SetBoundary(m.boundary); err != nil {
+			return nil, err
+		}
+	}
+
+	return mw, nil
+}
+
+// contentType returns the Content-Type of a body written by mw.
+func (m *Multipart) contentType(mw *multipart.Writer) string {
+	params := map[string]string{"boundary": mw.Boundary()}
+	for k, v := range m.params {
+		params[k] = v
+	}
+
+	return mime.FormatMediaType("multipart/"+m.subtype, params)
+}
+
+// writeTo writes every part to mw. With keepGoing, a part whose source
+// cannot be opened is skipped and the remaining parts are still written;
+// every error is returned.
+func (m *Multipart) writeTo(mw *multipart.Writer, keepGoing bool) []error {
+	var errs []error
+	for _, p := range m.parts {
+		if err := p.writeTo(mw); err != nil {
+			errs = append(errs, err)
+			if !keepGoing {
+				return errs
+			}
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close writer: %w", err))
+	}
+
+	return errs
+}
+
+// writeTo writes a single part.
+func (p multipartPart) writeTo(mw *multipart.Writer) error {
+	r, closer, err := p.open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", p.name, err)
+	}
+	if closer != nil {
+		defer closer() //nolint:errcheck
+	}
+
+	w, err := mw.CreatePart(p.header)
+	if err != nil {
+		return fmt.Errorf("failed to create part %s: %w", p.name, err)
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", p.name, err)
+	}
+
+	return nil
+}
+
+// SetMultipart sets a multipart body built with NewMultipart,
+// NewMultipartRelated or NewMultipartMixed. The body is buffered: a part
+// that cannot be read is reported by Err and RunE.
+func (rc *RequestConfig) SetMultipart(m *Multipart) *RequestConfig {
+	return rc.setMultipart("SetMultipart", m)
+}
+
+// setMultipart buffers m as the request body, recording errors under op.
+func (rc *RequestConfig) setMultipart(op string, m *Multipart) *RequestConfig {
+	body := new(bytes.Buffer)
+	mw, err := m.newWriter(body)
+	if err != nil {
+		rc.addError(fmt.Errorf("%s: %w", op, err))
+		return rc
+	}
+
+	for _, err := range m.writeTo(mw, true) {
+		rc.addError(fmt.Errorf("%s: %w", op, err))
+	}
+
+	rc.SetBodyBytes(body.Bytes())
+	rc.ContentType = m.contentType(mw)
+
+	return rc
+}
+
+// SetMultipartStream sets a multipart body that is produced while the
+// handler reads it, through an io.Pipe, so large files are never held in
+// memory. A part that cannot be read aborts the body: the handler observes
+// the error when reading the request. The body is closed once the handler
+// returns, which stops the producer when the handler did not read it all.
+func (rc *RequestConfig) SetMultipartStream(m *Multipart) *RequestConfig {
+	pr, pw := io.Pipe()
+	mw, err := m.newWriter(pw)
+	if err != nil {
+		rc.addError(fmt.Errorf("SetMultipartStream: %w", err))
+		return rc
+	}
+
+	rc.SetBodyReader(&lazyReader{start: func() io.ReadCloser {
+		go func() {
+			if errs := m.writeTo(mw, false); len(errs) > 0 {
+				_ = pw.CloseWithError(errs[0])
+				return
+			}
+			_ = pw.Close()
+		}()
+		return pr
+	}})
+	rc.ContentType = m.contentType(mw)
+
+	return rc
+}
+
+// lazyReader defers starting a producer until the first Read, so a body
+// that is never read does not leak a goroutine. Closing it closes the
+// producer's reader, so that a producer blocked on a body the handler
+// stopped reading returns.
+type lazyReader struct {
+	start func() io.ReadCloser
+
+	mu     sync.Mutex
+	r      io.ReadCloser
+	closed bool
+}
+
+// Read implements io.Reader.
+func (l *lazyReader) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	if l.r == nil {
+		l.r = l.start()
+	}
+	r := l.r
+	l.mu.Unlock()
+
+	return r.Read(p)
+}
+
+// Close implements io.Closer. The producer is not started when it was
+// never read.
+func (l *lazyReader) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	if l.r == nil {
+		return nil
+	}
+
+	return l.r.Close()
+}
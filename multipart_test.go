@@ -0,0 +1,210 @@
+package gofight
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// multipartEchoHandler lists every part of a multipart body in order
+func multipartEchoHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get(ContentType))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		http.Error(w, "not multipart", http.StatusBadRequest)
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "%s type=%s\n", mediaType, params["type"])
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(p)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%s|%s|%s|%s|%s\n",
+			p.FormName(), p.FileName(), p.Header.Get(ContentType), p.Header.Get("X-Part"), body)
+	}
+}
+
+// TestSetMultipart tests ordered fields, typed parts and repeated file fields
+func TestSetMultipart(t *testing.T) {
+	fsys := fstest.MapFS{"docs/readme.md": {Data: []byte("# readme")}}
+
+	m := NewMultipart().
+		Field("z", "last").
+		Field("a", "first").
+		Field("meta", `{"public":true}`, PartContentType(ApplicationJSON), PartHeader("X-Part", "1")).
+		FileFromPath("files", "testdata/hello.txt").
+		FileFromPath("files", "testdata/world.txt").
+		FileFromFS("doc", fsys, "docs/readme.md").
+		File("blob", "data", strings.NewReader("raw")).
+		FileBytes("image", "pixel.png", []byte("png"))
+
+	New().POST("/upload").
+		SetMultipart(m).
+		Run(http.HandlerFunc(multipartEchoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+			assert.Equal(t, strings.Join([]string{
+				"multipart/form-data type=",
+				"z||||last",
+				"a||||first",
+				`meta||application/json|1|{"public":true}`,
+				"files|hello.txt|text/plain; charset=utf-8||world\n",
+				"files|world.txt|text/plain; charset=utf-8||hello\n",
+				"doc|readme.md|text/markdown; charset=utf-8||# readme",
+				"blob|data|application/octet-stream||raw",
+				"image|pixel.png|image/png||png",
+				"",
+			}, "\n"), r.Body.String())
+		})
+}
+
+// TestSetMultipartFormParsing tests that handlers can use ParseMultipartForm
+func TestSetMultipartFormParsing(t *testing.T) {
+	m := NewMultipart().
+		FileFromPath("files", "testdata/hello.txt").
+		FileFromPath("files", "testdata/world.txt").
+		Field("tag", "a").
+		Field("tag", "b")
+
+	New().POST("/upload").
+		SetMultipart(m).
+		Run(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			assert.Len(t, r.MultipartForm.File["files"], 2)
+			assert.Equal(t, []string{"a", "b"}, r.MultipartForm.Value["tag"])
+		}), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+}
+
+// TestSetMultipartRelated tests multipart/related bodies with raw parts
+func TestSetMultipartRelated(t *testing.T) {
+	meta := textproto.MIMEHeader{}
+	meta.Set(ContentType, ApplicationJSON)
+	meta.Set("Content-ID", "<meta>")
+
+	m := NewMultipartRelated(ApplicationJSON).
+		SetBoundary("gofight-boundary").
+		SetParam("start", "<meta>").
+		Part(meta, strings.NewReader(`{"name":"a.bin"}`)).
+		Part(textproto.MIMEHeader{"content-type": {ApplicationOctetStream}}, strings.NewReader("\x00\x01"))
+
+	New().POST("/upload").
+		SetMultipart(m).
+		Run(http.HandlerFunc(multipartEchoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t,
+				`multipart/related; boundary=gofight-boundary; start="<meta>"; type="application/json"`,
+				rq.Header.Get(ContentType))
+			assert.Equal(t, "multipart/related type=application/json\n"+
+				`||application/json||{"name":"a.bin"}`+"\n"+
+				"||application/octet-stream||\x00\x01\n", r.Body.String())
+		})
+
+	New().POST("/upload").
+		SetMultipart(NewMultipartMixed().Field("a", "1")).
+		Run(http.HandlerFunc(multipartEchoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "multipart/mixed type=\na||||1\n", r.Body.String())
+		})
+}
+
+// TestSetMultipartErrors tests that unreadable parts are reported
+func TestSetMultipartErrors(t *testing.T) {
+	r := New().POST("/upload").
+		SetMultipart(NewMultipart().
+			FileFromPath("missing", "testdata/missing.txt").
+			Field("a", "1"))
+	assert.ErrorIs(t, r.Err(), os.ErrNotExist)
+
+	r.Run(http.HandlerFunc(multipartEchoHandler), func(r HTTPResponse, rq HTTPRequest) {
+		assert.Equal(t, "multipart/form-data type=\na||||1\n", r.Body.String())
+	})
+
+	assert.Error(t, New().SetMultipart(NewMultipart().SetBoundary("bad boundary!")).Err())
+}
+
+// TestSetMultipartStream tests streaming multipart bodies through a pipe
+func TestSetMultipartStream(t *testing.T) {
+	m := NewMultipart().
+		Field("a", "1").
+		FileFromPath("file", "testdata/hello.txt")
+
+	New().POST("/upload").
+		SetMultipartStream(m).
+		Run(http.HandlerFunc(multipartEchoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, int64(-1), rq.ContentLength)
+			assert.Equal(t, "multipart/form-data type=\na||||1\nfile|hello.txt|text/plain; charset=utf-8||world\n\n", r.Body.String())
+		})
+
+	broken := NewMultipart().
+		Field("a", "1").
+		FileFromPath("file", "testdata/missing.txt")
+
+	New().POST("/upload").
+		SetMultipartStream(broken).
+		Run(http.HandlerFunc(multipartEchoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Contains(t, r.Body.String(), "failed to open testdata/missing.txt")
+		})
+}
+
+// goroutinesSettle waits for the number of goroutines to drop back to n
+func goroutinesSettle(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), n, "leaked goroutines")
+}
+
+// TestSetMultipartStreamRejected tests that a handler rejecting the upload
+// does not leave the producer blocked
+func TestSetMultipartStreamRejected(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), 1<<20)
+	handlers := map[string]http.HandlerFunc{
+		"unread": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		},
+		"partial": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = r.Body.Read(make([]byte, 1))
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		},
+	}
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			for range 20 {
+				New().POST("/upload").
+					SetMultipartStream(NewMultipart().FileBytes("file", "big.bin", big)).
+					Run(handler, func(r HTTPResponse, rq HTTPRequest) {
+						assert.Equal(t, http.StatusRequestEntityTooLarge, r.Code)
+					})
+			}
+			goroutinesSettle(t, before)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package gofight
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+)
+
+// SetNDJSON sends values as a newline delimited JSON (JSON Lines) body,
+// one value per line, encoded as the handler reads the body. values may be
+// a slice, a channel, read until it is closed, or an iter.Seq, so a test
+// can produce the next value once it has read the response to the
+// previous one, see Start and DecodeLines:
+//
+//	in := make(chan Record)
+//	rec, err := gofight.New().POST("/ingest").SetNDJSON(in).Start(engine)
+//	require.NoError(t, err)
+//	out := gofight.DecodeLines[Result](rec)
+//
+//	in <- Record{ID: 1}
+//	res, err := out.Next()
+//
+// The body is sent with chunked transfer encoding and the
+// application/x-ndjson content type, unless SetContentType is called
+// afterwards. It cannot be replayed, so SetDigestAuth fails with it. A
+// value that cannot be encoded fails the read of the body by the handler.
+func (rc *RequestConfig) SetNDJSON(values any) *RequestConfig {
+	seq, err := ndjsonValues(values)
+	if err != nil {
+		rc.addError(fmt.Errorf("SetNDJSON: %w", err))
+		return rc
+	}
+
+	return rc.SetBodyReader(ndjsonBody(seq)).
+		SetContentType(ApplicationNDJSON)
+}
+
+// ndjsonValues returns the values of a slice, a channel or an iter.Seq.
+func ndjsonValues(values any) (iter.Seq[any], error) {
+	rv := reflect.ValueOf(values)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return func(yield func(any) bool) {
+			for i := range rv.Len() {
+				if !yield(rv.Index(i).Interface()) {
+					return
+				}
+			}
+		}, nil
+	case reflect.Chan:
+		if rv.Type().ChanDir()&reflect.RecvDir == 0 {
+			return nil, fmt.Errorf("cannot receive from %T", values)
+		}
+		return func(yield func(any) bool) {
+			for {
+				v, ok := rv.Recv()
+				if !ok || !yield(v.Interface()) {
+					return
+				}
+			}
+		}, nil
+	case reflect.Func:
+		t := rv.Type()
+		if t.NumIn() != 1 || t.NumOut() != 0 {
+			break
+		}
+		yieldType := t.In(0)
+		if yieldType.Kind() != reflect.Func || yieldType.NumIn() != 1 ||
+			yieldType.NumOut() != 1 || yieldType.Out(0).Kind() != reflect.Bool {
+			break
+		}
+		return func(yield func(any) bool) {
+			rv.Call([]reflect.Value{reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(yield(args[0].Interface())).Convert(yieldType.Out(0))}
+			})})
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %T, want a slice, a channel or an iter.Seq", values)
+}
+
+// ndjsonBody returns a body encoding values as they are read. Encoding
+// starts with the first Read, so that a request that is never sent
+// consumes no value, and stops once the body is closed, when the handler
+// returns. With a channel, the encoder waiting for the next value returns
+// once the test sends it or closes the channel.
+func ndjsonBody(values iter.Seq[any]) io.ReadCloser {
+	return &lazyReader{start: func() io.ReadCloser {
+		pr, pw := io.Pipe()
+		go func() {
+			i := 0
+			for v := range values {
+				line, err := json.Marshal(v)
+				if err != nil {
+					_ = pw.CloseWithError(fmt.Errorf("SetNDJSON: value %d: %w", i, err))
+					return
+				}
+				if _, err := pw.Write(append(line, '\n')); err != nil {
+					return
+				}
+				i++
+			}
+			_ = pw.Close()
+		}()
+		return pr
+	}}
+}
+
+// LineDecoder decodes a newline delimited JSON response line by line, as
+// the handler flushes it. Empty lines are skipped.
+type LineDecoder[T any] struct {
+	rec  *Recorder
+	buf  []byte
+	line int
+	eof  bool
+}
+
+// DecodeLines returns a LineDecoder reading the chunks of rec, the
+// Recorder returned by Start or the one of an HTTPResponse. Reading chunks
+// with rec.NextChunk as well skips them for the decoder.
+func DecodeLines[T any](rec *Recorder) *LineDecoder[T] {
+	return &LineDecoder[T]{rec: rec}
+}
+
+// Next returns the value of the next line. It waits for the handler like
+// Recorder.NextChunk, and returns io.EOF once every line was read.
+func (d *LineDecoder[T]) Next() (T, error) {
+	var zero T
+	for {
+		if i := bytes.IndexByte(d.buf, '\n'); i >= 0 || (d.eof && len(d.buf) > 0) {
+			if i < 0 {
+				i = len(d.buf)
+			}
+			line := bytes.TrimSpace(d.buf[:i])
+			d.buf = d.buf[min(i+1, len(d.buf)):]
+			d.line++
+			if len(line) == 0 {
+				continue
+			}
+
+			var v T
+			if err := json.Unmarshal(line, &v); err != nil {
+				return zero, fmt.Errorf("line %d %q: %w", d.line, line, err)
+			}
+			return v, nil
+		}
+		if d.eof {
+			return zero, io.EOF
+		}
+
+		chunk, err := d.rec.NextChunk()
+		if errors.Is(err, io.EOF) {
+			d.eof = true
+			continue
+		}
+		if err != nil {
+			return zero, err
+		}
+		d.buf = append(d.buf, chunk...)
+	}
+}
+
+// All returns an iterator over the remaining values. It stops after the
+// last line, or after yielding the first error.
+func (d *LineDecoder[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			v, err := d.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(v, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package gofight
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ndjsonRecord struct {
+	ID int `json:"id"`
+}
+
+type ndjsonResult struct {
+	ID    int `json:"id"`
+	Total int `json:"total"`
+}
+
+// ndjsonHandler answers every line of the request body with a line holding
+// the running total of the ids, flushing after each one
+func ndjsonHandler(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+	w.Header().Set(ContentType, ApplicationNDJSON)
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	total := 0
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var rec ndjsonRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			_, _ = io.WriteString(w, `{"error": true}`+"\n")
+			return
+		}
+		total += rec.ID
+		_ = json.NewEncoder(w).Encode(ndjsonResult{ID: rec.ID, Total: total})
+		_ = rc.Flush()
+	}
+	if err := scanner.Err(); err != nil {
+		_, _ = io.WriteString(w, err.Error())
+	}
+}
+
+// TestNDJSONFullDuplex tests values sent once the answer to the previous
+// one was read
+func TestNDJSONFullDuplex(t *testing.T) {
+	for _, mode := range []Mode{ModeRecorder, ModeServer, ModeHTTP2} {
+		t.Run(mode.String(), func(t *testing.T) {
+			in := make(chan ndjsonRecord)
+			rec, err := NewT(t).POST("/ingest").SetMode(mode).SetNDJSON(in).Start(http.HandlerFunc(ndjsonHandler))
+			require.NoError(t, err)
+			out := DecodeLines[ndjsonResult](rec)
+
+			for i, want := range []ndjsonResult{{1, 1}, {2, 3}, {3, 6}} {
+				in <- ndjsonRecord{ID: i + 1}
+				got, err := out.Next()
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+
+			close(in)
+			_, err = out.Next()
+			assert.Equal(t, io.EOF, err)
+			require.NoError(t, rec.Wait())
+			assert.Equal(t, ApplicationNDJSON, rec.Header().Get(ContentType))
+		})
+	}
+}
+
+// TestNDJSONSources tests the slice and iter.Seq sources
+func TestNDJSONSources(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, strings.Join(r.TransferEncoding, ",")+" "+r.Header.Get(ContentType)+"\n")
+		_, _ = io.Copy(w, r.Body)
+	})
+
+	sources := map[string]any{
+		"slice":  []ndjsonRecord{{1}, {2}},
+		"array":  [2]any{ndjsonRecord{1}, map[string]int{"id": 2}},
+		"seq":    slices.Values([]ndjsonRecord{{1}, {2}}),
+		"seqAny": func(yield func(any) bool) { _ = yield(ndjsonRecord{1}) && yield(ndjsonRecord{2}) },
+	}
+	NewT(t).POST("/").SetNDJSON([]int{1}).SetContentType("application/jsonl").
+		Run(handler, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "chunked application/jsonl\n1\n", r.Body.String())
+		})
+
+	for name, values := range sources {
+		t.Run(name, func(t *testing.T) {
+			NewT(t).POST("/").SetMode(ModeServer).SetNDJSON(values).
+				Run(handler, func(r HTTPResponse, rq HTTPRequest) {
+					assert.Equal(t, "chunked application/x-ndjson\n{\"id\":1}\n{\"id\":2}\n", r.Body.String())
+				})
+		})
+	}
+}
+
+// TestNDJSONErrors tests unsupported sources and values
+func TestNDJSONErrors(t *testing.T) {
+	for _, values := range []any{"text", nil, make(chan<- int), func(int) {}} {
+		_, err := New().POST("/").SetNDJSON(values).Start(http.NotFoundHandler())
+		assert.ErrorContains(t, err, "SetNDJSON: ")
+	}
+
+	rec, err := New().POST("/").SetNDJSON([]any{ndjsonRecord{1}, make(chan int)}).
+		Start(http.HandlerFunc(ndjsonHandler))
+	require.NoError(t, err)
+	require.NoError(t, rec.Wait())
+	assert.Equal(t, `{"id":1,"total":1}`+"\n"+
+		"SetNDJSON: value 1: json: unsupported type: chan int", rec.Body.String())
+}
+
+// TestNDJSONPartialRead tests that a handler returning before the end of
+// the body does not leave the encoder blocked
+func TestNDJSONPartialRead(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = r.Body.Read(make([]byte, 1))
+	})
+	endless := func(yield func(int) bool) {
+		for i := 0; yield(i); i++ {
+		}
+	}
+
+	before := runtime.NumGoroutine()
+	for range 20 {
+		New().POST("/").SetNDJSON(endless).Run(handler, func(r HTTPResponse, rq HTTPRequest) {})
+
+		rec, err := New().POST("/").SetNDJSON(endless).Start(handler)
+		require.NoError(t, err)
+		require.NoError(t, rec.Wait())
+	}
+	goroutinesSettle(t, before)
+}
+
+// TestDecodeLines tests the line decoder on a recorded response
+func TestDecodeLines(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"id": 1}`+"\n"+`{"id"`)
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, `: 2}`+"\r\n\n"+`{"id": 3}`)
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, "\n"+`{"id": "four"}`+"\n"+`{"id": 5}`)
+	})
+
+	NewT(t).GET("/").Run(handler, func(r HTTPResponse, rq HTTPRequest) {
+		var (
+			ids  []int
+			errs []error
+		)
+		for v, err := range DecodeLines[ndjsonRecord](r.Recorder).All() {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			ids = append(ids, v.ID)
+		}
+		assert.Equal(t, []int{1, 2, 3}, ids)
+		require.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], `line 5 "{\"id\": \"four\"}": json: cannot unmarshal`)
+
+		_, err := DecodeLines[ndjsonRecord](r.Recorder).Next()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
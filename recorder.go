@@ -0,0 +1,458 @@
+package gofight
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+)
+
+// recorderDefaultTimeout bounds NextChunk and Wait.
+const recorderDefaultTimeout = 5 * time.Second
+
+// CallKind is the kind of a ResponseWriter call recorded by a Recorder.
+type CallKind int
+
+// Recorded ResponseWriter calls. The calls after CallFlush are made
+// through an http.ResponseController.
+const (
+	CallWriteHeader CallKind = iota + 1
+	CallWrite
+	CallFlush
+	CallSetReadDeadline
+	CallSetWriteDeadline
+	CallEnableFullDuplex
+	CallHijack
+)
+
+// String returns the name of the call.
+func (k CallKind) String() string {
+	switch k {
+	case CallWriteHeader:
+		return "WriteHeader"
+	case CallWrite:
+		return "Write"
+	case CallFlush:
+		return "Flush"
+	case CallSetReadDeadline:
+		return "SetReadDeadline"
+	case CallSetWriteDeadline:
+		return "SetWriteDeadline"
+	case CallEnableFullDuplex:
+		return "EnableFullDuplex"
+	case CallHijack:
+		return "Hijack"
+	default:
+		return fmt.Sprintf("CallKind(%d)", int(k))
+	}
+}
+
+// Call is a ResponseWriter call made by the handler.
+type Call struct {
+	Kind CallKind
+	// Time is when the call was made, and Elapsed the time since the
+	// Recorder was created.
+	Time    time.Time
+	Elapsed time.Duration
+	// Code is the status code of a WriteHeader call.
+	Code int
+	// Bytes is the length written by a Write call, or the length flushed
+	// by a Flush call.
+	Bytes int
+	// Superfluous is set on a WriteHeader call made after the header was
+	// written or the connection hijacked, which net/http ignores with a
+	// warning.
+	Superfluous bool
+	// Deadline is the deadline of a SetReadDeadline or SetWriteDeadline
+	// call.
+	Deadline time.Time
+}
+
+// Recorder is an httptest.ResponseRecorder that also records the timeline
+// of the WriteHeader, Write and Flush calls of the handler, and splits the
+// body into chunks at every flush. It is the recorder of HTTPResponse.
+//
+// The chunks can be read with NextChunk while the handler is still
+// running, see RequestConfig.Start. The fields of the embedded
+// ResponseRecorder must only be read once the handler returned.
+//
+// Handlers using an http.ResponseController work as with a server: the
+// Recorder implements SetReadDeadline, SetWriteDeadline, EnableFullDuplex
+// and Hijack, also found through middleware wrappers implementing
+// Unwrap() http.ResponseWriter, and records their calls.
+//
+// Informational 1xx status codes other than 101 are recorded in the
+// timeline but do not replace the final status code. In every mode but
+// ModeRecorder, the Recorder holds the response read by the client: each
+// read is recorded as a Write and forms a chunk.
+type Recorder struct {
+	*httptest.ResponseRecorder
+
+	mu          sync.Mutex
+	start       time.Time
+	calls       []Call
+	wroteHeader bool
+	pending     []byte
+	chunks      [][]byte
+	next        int
+	notify      chan struct{}
+	done        chan struct{}
+	finishOnce  sync.Once
+	panicValue  any
+	timeout     time.Duration
+
+	// input is what a hijacked connection reads: the rest of the request
+	// body.
+	input    io.Reader
+	hijacked *hijackedConn
+	raw      []byte
+}
+
+// NewRecorder returns an initialized Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		ResponseRecorder: httptest.NewRecorder(),
+		start:            time.Now(),
+		notify:           make(chan struct{}, 1),
+		done:             make(chan struct{}),
+		timeout:          recorderDefaultTimeout,
+	}
+}
+
+// record appends a call to the timeline. The caller holds mu.
+func (r *Recorder) record(call Call) {
+	call.Time = time.Now()
+	call.Elapsed = call.Time.Sub(r.start)
+	r.calls = append(r.calls, call)
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *Recorder) WriteHeader(code int) {
+	r.mu.Lock()
+	informational := code >= 100 && code < 200 && code != http.StatusSwitchingProtocols
+	superfluous := r.wroteHeader || r.hijacked != nil
+	r.record(Call{Kind: CallWriteHeader, Code: code, Superfluous: superfluous})
+	if !informational {
+		r.wroteHeader = true
+	}
+	r.mu.Unlock()
+
+	if !informational && !superfluous {
+		r.ResponseRecorder.WriteHeader(code)
+	}
+}
+
+// Write implements http.ResponseWriter.
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	if r.hijacked != nil {
+		r.mu.Unlock()
+		return 0, http.ErrHijacked
+	}
+	r.record(Call{Kind: CallWrite, Bytes: len(b)})
+	r.wroteHeader = true
+	r.pending = append(r.pending, b...)
+	r.mu.Unlock()
+
+	return r.ResponseRecorder.Write(b)
+}
+
+// WriteString implements io.StringWriter.
+func (r *Recorder) WriteString(s string) (int, error) {
+	return r.Write([]byte(s))
+}
+
+// Flush implements http.Flusher.
+func (r *Recorder) Flush() {
+	_ = r.FlushError()
+}
+
+// FlushError flushes like Flush, and fails once the connection was
+// hijacked. It is called by http.ResponseController.Flush.
+func (r *Recorder) FlushError() error {
+	r.mu.Lock()
+	if r.hijacked != nil {
+		r.mu.Unlock()
+		return http.ErrHijacked
+	}
+	r.record(Call{Kind: CallFlush, Bytes: len(r.pending)})
+	r.wroteHeader = true
+	r.cut()
+	r.mu.Unlock()
+
+	r.ResponseRecorder.Flush()
+
+	return nil
+}
+
+// cut turns the bytes written since the last flush into a chunk. The
+// caller holds mu.
+func (r *Recorder) cut() {
+	if len(r.pending) == 0 {
+		return
+	}
+
+	r.chunks = append(r.chunks, r.pending)
+	r.pending = nil
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// finish marks the end of the handler, turning the remaining bytes into a
+// chunk.
+func (r *Recorder) finish() {
+	r.finishOnce.Do(func() {
+		r.mu.Lock()
+		r.cut()
+		r.mu.Unlock()
+		close(r.done)
+	})
+}
+
+// Timeline returns the calls made so far, in order.
+func (r *Recorder) Timeline() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Call(nil), r.calls...)
+}
+
+// Calls returns the calls of the given kind made so far, in order.
+func (r *Recorder) Calls(kind CallKind) []Call {
+	var calls []Call
+	for _, call := range r.Timeline() {
+		if call.Kind == kind {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// Superfluous returns the WriteHeader calls made after the header was
+// written or the connection hijacked.
+func (r *Recorder) Superfluous() []Call {
+	var calls []Call
+	for _, call := range r.Calls(CallWriteHeader) {
+		if call.Superfluous {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// TimeToFirstByte returns the time from the creation of the Recorder to
+// the first Write of at least one byte, and false if none was made.
+func (r *Recorder) TimeToFirstByte() (time.Duration, bool) {
+	for _, call := range r.Timeline() {
+		if call.Kind == CallWrite && call.Bytes > 0 {
+			return call.Elapsed, true
+		}
+	}
+
+	return 0, false
+}
+
+// Chunks returns the chunks of the body so far: the bytes written between
+// two flushes, and once the handler returned, the bytes written after the
+// last flush.
+func (r *Recorder) Chunks() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([][]byte(nil), r.chunks...)
+}
+
+// SetTimeout sets the time allowed for every following NextChunk and Wait.
+func (r *Recorder) SetTimeout(d time.Duration) *Recorder {
+	r.timeout = d
+
+	return r
+}
+
+// NextChunk returns the next chunk not returned yet. It waits for the
+// handler to flush, and returns io.EOF once the handler returned with
+// every chunk read, or an error wrapping os.ErrDeadlineExceeded after the
+// timeout.
+func (r *Recorder) NextChunk() ([]byte, error) {
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+
+	for {
+		r.mu.Lock()
+		if r.next < len(r.chunks) {
+			chunk := r.chunks[r.next]
+			r.next++
+			r.mu.Unlock()
+			return chunk, nil
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-r.done:
+			r.mu.Lock()
+			more := r.next < len(r.chunks)
+			r.mu.Unlock()
+			if !more {
+				return nil, io.EOF
+			}
+		case <-r.notify:
+		case <-timer.C:
+			return nil, fmt.Errorf("no chunk within %s: %w", r.timeout, os.ErrDeadlineExceeded)
+		}
+	}
+}
+
+// Done returns a channel closed when the handler returned.
+func (r *Recorder) Done() <-chan struct{} {
+	return r.done
+}
+
+// Wait waits for the handler to return. It returns an error wrapping
+// os.ErrDeadlineExceeded after the timeout, or when the handler started
+// with Start panicked, an error holding the panic value.
+func (r *Recorder) Wait() error {
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-r.done:
+	case <-timer.C:
+		return fmt.Errorf("handler still running after %s: %w", r.timeout, os.ErrDeadlineExceeded)
+	}
+
+	if r.panicValue != nil {
+		return fmt.Errorf("handler panicked: %v", r.panicValue)
+	}
+
+	return nil
+}
+
+// Start sends the request and returns the Recorder of the response right
+// away, while the handler runs in the background, so that chunks can be
+// read as the handler flushes them:
+//
+//	rec, err := gofight.New().GET("/report").Start(engine)
+//	require.NoError(t, err)
+//
+//	first, err := rec.NextChunk()
+//	require.NoError(t, err)
+//	assert.Equal(t, "<html><head>", string(first))
+//	require.NoError(t, rec.Wait())
+//	assert.Equal(t, http.StatusOK, rec.Code)
+//
+// In ModeRecorder the handler is called in a goroutine, otherwise the
+// response is read from the connection as it arrives. SetDigestAuth is
+// not applied.
+func (rc *RequestConfig) Start(r http.Handler) (*Recorder, error) {
+	if rc.t != nil {
+		rc.t.Helper()
+	}
+
+	if rc.mode != ModeRecorder {
+		resp, stop, err := rc.stream(r)
+		if err != nil {
+			return nil, err
+		}
+
+		rec := NewRecorder()
+		go func() {
+			defer rec.finish()
+			defer stop()
+			defer resp.Body.Close()
+			_ = rec.copyResponse(resp)
+		}()
+
+		return rec, nil
+	}
+
+	if err := rc.Err(); err != nil {
+		return nil, err
+	}
+
+	h, err := rc.handler(r)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := rc.newRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	secure := rc.isSecureContext()
+	if rc.session != nil {
+		rc.session.prepare(req, secure)
+	}
+
+	rec := NewRecorder()
+	rec.input = req.Body
+	go func() {
+		defer rec.finish()
+		defer func() {
+			if req.Body != nil {
+				_ = req.Body.Close()
+			}
+		}()
+		defer func() {
+			if v := recover(); v != nil {
+				rec.panicValue = v
+			}
+		}()
+
+		h.ServeHTTP(rec, req)
+		if rc.session != nil {
+			rc.session.store(req, rec.Result().Cookies(), secure)
+		}
+	}()
+
+	return rec, nil
+}
+
+// recordResponse copies a client response into a Recorder, so that
+// ResponseFuncs work the same in every mode.
+func recordResponse(resp *http.Response) (*Recorder, error) {
+	rec := NewRecorder()
+	defer rec.finish()
+
+	return rec, rec.copyResponse(resp)
+}
+
+// copyResponse writes a client response to the Recorder as it is read,
+// each read forming a chunk. Trailers are recorded under
+// http.TrailerPrefix.
+func (r *Recorder) copyResponse(resp *http.Response) error {
+	for k, v := range resp.Header {
+		r.Header()[k] = v
+	}
+	r.WriteHeader(resp.StatusCode)
+
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			_, _ = r.Write(buf[:n])
+			r.mu.Lock()
+			r.cut()
+			r.mu.Unlock()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read response body: %w", err)
+		}
+	}
+
+	for k, v := range resp.Trailer {
+		r.Header()[http.TrailerPrefix+k] = v
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package gofight
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// progressiveHandler flushes a first chunk, then waits for release before
+// writing the rest of the body
+func progressiveHandler(release <-chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ContentType, "text/html")
+		_, _ = io.WriteString(w, "<html><head>")
+		http.NewResponseController(w).Flush() //nolint:errcheck
+
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = io.WriteString(w, "<body>")
+		_, _ = io.WriteString(w, "</html>")
+	}
+}
+
+// TestRecorderTimeline tests the calls recorded during Run
+func TestRecorderTimeline(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("a"))
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte("b"))
+		_, _ = io.WriteString(w, "cd")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	NewT(t).GET("/").Run(handler, func(r HTTPResponse, rq HTTPRequest) {
+		assert.Equal(t, http.StatusCreated, r.Code)
+		assert.Equal(t, "abcd", r.Body.String())
+		assert.True(t, r.Flushed)
+
+		var calls []Call
+		for _, call := range r.Timeline() {
+			assert.False(t, call.Time.IsZero())
+			assert.GreaterOrEqual(t, call.Elapsed, time.Duration(0))
+			call.Time, call.Elapsed = time.Time{}, 0
+			calls = append(calls, call)
+		}
+		assert.Equal(t, []Call{
+			{Kind: CallWriteHeader, Code: http.StatusEarlyHints},
+			{Kind: CallWriteHeader, Code: http.StatusCreated},
+			{Kind: CallWrite, Bytes: 1},
+			{Kind: CallFlush, Bytes: 1},
+			{Kind: CallWrite, Bytes: 1},
+			{Kind: CallWrite, Bytes: 2},
+			{Kind: CallWriteHeader, Code: http.StatusInternalServerError, Superfluous: true},
+		}, calls)
+
+		require.Len(t, r.Superfluous(), 1)
+		assert.Equal(t, http.StatusInternalServerError, r.Superfluous()[0].Code)
+		ttfb, ok := r.TimeToFirstByte()
+		assert.True(t, ok)
+		assert.Equal(t, r.Timeline()[2].Elapsed, ttfb)
+
+		r.Expect().Chunks("a", "bcd").FirstByteWithin(time.Second)
+
+		err := (&Expectation{resp: r}).Chunks("abcd").NoSuperfluousWriteHeader().Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `expected body chunks ["abcd"], got ["a" "bcd"]`)
+		assert.Contains(t, err.Error(), "unexpected superfluous WriteHeader(500)")
+	})
+}
+
+// TestRecorderFirstByte tests the time to first byte expectation
+func TestRecorderFirstByte(t *testing.T) {
+	NewT(t).GET("/").Run(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = io.WriteString(w, "late")
+	}), func(r HTTPResponse, rq HTTPRequest) {
+		ttfb, ok := r.TimeToFirstByte()
+		require.True(t, ok)
+		assert.GreaterOrEqual(t, ttfb, 20*time.Millisecond)
+
+		err := (&Expectation{resp: r}).FirstByteWithin(time.Millisecond).Err()
+		assert.ErrorContains(t, err, "expected first byte within 1ms, got")
+	})
+
+	NewT(t).GET("/").Run(http.NotFoundHandler(), func(r HTTPResponse, rq HTTPRequest) {
+		r.Expect().NoSuperfluousWriteHeader()
+		assert.EqualError(t, (&Expectation{resp: HTTPResponse{Recorder: NewRecorder()}}).FirstByteWithin(time.Second).Err(),
+			"expected first byte within 1s, but the body is empty")
+	})
+}
+
+// TestStart tests chunks read while the handler is running
+func TestStart(t *testing.T) {
+	for _, mode := range []Mode{ModeRecorder, ModeServer, ModeHTTP2} {
+		t.Run(mode.String(), func(t *testing.T) {
+			release := make(chan struct{})
+			rec, err := NewT(t).GET("/").SetMode(mode).Start(progressiveHandler(release))
+			require.NoError(t, err)
+
+			chunk, err := rec.NextChunk()
+			require.NoError(t, err)
+			assert.Equal(t, "<html><head>", string(chunk))
+
+			_, err = rec.SetTimeout(20 * time.Millisecond).NextChunk()
+			require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+			require.ErrorIs(t, rec.Wait(), os.ErrDeadlineExceeded)
+
+			close(release)
+			rec.SetTimeout(time.Second)
+			var rest strings.Builder
+			for {
+				chunk, err := rec.NextChunk()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				rest.Write(chunk)
+			}
+			assert.Equal(t, "<body></html>", rest.String())
+
+			require.NoError(t, rec.Wait())
+			<-rec.Done()
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "text/html", rec.Header().Get(ContentType))
+			assert.Equal(t, "<html><head><body></html>", rec.Body.String())
+		})
+	}
+}
+
+// TestStartKeepsMode tests that Start does not switch a reused builder to
+// a server
+func TestStartKeepsMode(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.RemoteAddr)
+	})
+
+	rc := NewT(t).GET("/").SetMode(ModeRecorder)
+	rec, err := rc.Start(handler)
+	require.NoError(t, err)
+	require.NoError(t, rec.Wait())
+
+	_, err = rc.OpenEventStream(handler)
+	assert.ErrorContains(t, err, "unexpected content type")
+
+	rc.Run(handler, func(r HTTPResponse, rq HTTPRequest) {
+		assert.Empty(t, r.Body.String(), "served by a Recorder, without a connection")
+	})
+}
+
+// TestStartErrors tests handlers that fail to run
+func TestStartErrors(t *testing.T) {
+	rec, err := New().GET("/").Start(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	require.NoError(t, err)
+	assert.EqualError(t, rec.Wait(), "handler panicked: boom")
+
+	_, err = New().GET("/").SetJSONInterface(make(chan int)).Start(http.NotFoundHandler())
+	assert.ErrorContains(t, err, "SetJSONInterface")
+
+	_, err = New().GET("/").Start(nil)
+	assert.EqualError(t, err, "no handler to run the request")
+}
+
+// TestRecorderServerMode tests the timeline of a response read by a client
+func TestRecorderServerMode(t *testing.T) {
+	NewT(t).GET("/").RunServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, "hello")
+	}), func(r HTTPResponse, rq HTTPRequest) {
+		assert.Equal(t, http.StatusAccepted, r.Code)
+		r.Expect().Chunks("hello").NoSuperfluousWriteHeader()
+
+		timeline := r.Timeline()
+		require.Len(t, timeline, 2)
+		assert.Equal(t, CallWriteHeader, timeline[0].Kind)
+		assert.Equal(t, Call{Kind: CallWrite, Bytes: 5}, Call{Kind: timeline[1].Kind, Bytes: timeline[1].Bytes})
+	})
+}
@@ -0,0 +1,76 @@
+package gofight
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// NewRemote supply initial structure for requests sent to a running server
+// at baseURL, see ModeRemote. The same builder and ResponseFunc code runs
+// in-process with New and against a real deployment with NewRemote:
+//
+//	func client() *gofight.RequestConfig {
+//	  if addr := os.Getenv("API_URL"); addr != "" {
+//	    return gofight.NewRemote(addr)
+//	  }
+//	  return gofight.New()
+//	}
+//
+//	client().GET("/health").Run(engine, func(r gofight.HTTPResponse, rq gofight.HTTPRequest) {
+//	  assert.Equal(t, http.StatusOK, r.Code)
+//	})
+//
+// A path in baseURL prefixes the request paths. An invalid baseURL is
+// reported by Err.
+func NewRemote(baseURL string) *RequestConfig {
+	rc := New()
+	rc.mode = ModeRemote
+	rc.baseURL = baseURL
+
+	u, err := url.Parse(baseURL)
+	switch {
+	case err != nil:
+		rc.addError(fmt.Errorf("NewRemote: %w", err))
+	case u.Scheme != "http" && u.Scheme != "https", u.Host == "":
+		rc.addError(fmt.Errorf("NewRemote: %q is not an absolute http or https URL", baseURL))
+	}
+
+	return rc
+}
+
+// SetHTTPClient sets the client used in ModeRemote, e.g. to configure
+// timeouts, TLS or a cookie jar. By default a client that does not follow
+// redirects is used, so that the ResponseFunc sees them as with a handler.
+func (rc *RequestConfig) SetHTTPClient(client *http.Client) *RequestConfig {
+	rc.client = client
+
+	return rc
+}
+
+// remoteExchanger returns a function sending requests to the base URL.
+func (rc *RequestConfig) remoteExchanger() (exchangeFunc, func(), error) {
+	client, stop := rc.remoteClient()
+
+	exchange := func(req *http.Request) (*Recorder, *http.Request, error) {
+		return send(client, rc.baseURL, req)
+	}
+
+	return exchange, stop, nil
+}
+
+// remoteClient returns the client of ModeRemote and a function releasing
+// its connections.
+func (rc *RequestConfig) remoteClient() (*http.Client, func()) {
+	if rc.client != nil {
+		return rc.client, func() {}
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return client, client.CloseIdleConnections
+}
@@ -0,0 +1,89 @@
+package gofight
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewRemote tests requests sent to a running server
+func TestNewRemote(t *testing.T) {
+	srv := httptest.NewServer(http.StripPrefix("/api", http.HandlerFunc(connInfoHandler)))
+	defer srv.Close()
+
+	// The same request and assertions run in-process and remotely.
+	check := func(rc *RequestConfig, handler http.Handler) {
+		rc.POST("/echo?x=1").
+			SetBody("a=1").
+			Run(handler, func(r HTTPResponse, rq HTTPRequest) {
+				assert.Equal(t, http.StatusOK, r.Code)
+				assert.Equal(t, "POST /echo?x=1 3 a=1", r.Body.String())
+				assert.Equal(t, ApplicationForm, rq.Header.Get(ContentType))
+			})
+	}
+	check(New(), http.HandlerFunc(connInfoHandler))
+	check(NewRemote(srv.URL+"/api/"), nil)
+
+	NewRemote(srv.URL).GET("/").
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusNotFound, r.Code)
+			assert.Equal(t, srv.URL+"/", rq.URL.String())
+		})
+}
+
+// TestNewRemoteRedirectAndClient tests redirects and custom clients
+func TestNewRemoteRedirectAndClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/new", http.StatusMovedPermanently))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("new"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	NewRemote(srv.URL).GET("/old").
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusMovedPermanently, r.Code)
+		})
+
+	NewRemote(srv.URL).GET("/old").
+		SetHTTPClient(srv.Client()).
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "new", r.Body.String())
+		})
+}
+
+// TestNewRemoteErrors tests invalid base URLs and unreachable servers
+func TestNewRemoteErrors(t *testing.T) {
+	assert.ErrorContains(t, NewRemote("localhost:8080").Err(), "not an absolute http or https URL")
+	assert.Error(t, NewRemote("http://[::1").Err())
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	err := NewRemote(srv.URL).GET("/").
+		RunE(nil, func(r HTTPResponse, rq HTTPRequest) {
+			t.Fatal("response func should not run")
+		})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to send request")
+
+	// Run does not call the response func with an empty response either.
+	assert.Panics(t, func() {
+		NewRemote(srv.URL).GET("/health").Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			t.Fatal("response func should not run")
+		})
+	})
+
+	tb := &recordingTB{TB: t}
+	rc := NewRemote(srv.URL).GET("/health")
+	rc.t = tb
+	rc.Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+		t.Fatal("response func should not run")
+	})
+	require.Len(t, tb.errors, 1)
+	assert.Contains(t, tb.errors[0], "failed to send request")
+}
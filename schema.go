@@ -0,0 +1,782 @@
+package gofight
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"math/big"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxSchemaDepth bounds $ref recursion so that a cyclic schema cannot loop
+// forever on a finite document.
+const maxSchemaDepth = 512
+
+// Schema is a compiled JSON Schema (draft 2020-12) used to validate
+// response bodies.
+//
+// The assertion vocabulary is supported: type, enum, const, numeric and
+// string bounds, pattern, array and object keywords, the applicators allOf,
+// anyOf, oneOf, not, if/then/else, dependentSchemas, and $ref to "#", JSON
+// pointers and $anchor names within the same document. format is treated as
+// an annotation, as the specification defaults to. unevaluatedProperties,
+// unevaluatedItems, references to other documents and embedded resources
+// ($id below the root) are not supported: compiling a schema using them
+// fails rather than ignoring them.
+type Schema struct {
+	root    any
+	anchors map[string]any
+
+	mu      sync.Mutex
+	regexps map[string]*regexp.Regexp
+}
+
+// SchemaViolation is one reason why a document does not match a Schema.
+type SchemaViolation struct {
+	// Pointer is the JSON pointer of the offending value, "" for the root.
+	Pointer string
+	// Keyword is the schema keyword that failed.
+	Keyword string
+	// Message describes the failure.
+	Message string
+}
+
+// String formats the violation as "pointer: message".
+func (v SchemaViolation) String() string {
+	ptr := v.Pointer
+	if ptr == "" {
+		ptr = "/"
+	}
+
+	return ptr + ": " + v.Message
+}
+
+// SchemaError is returned by Schema.Validate when a document does not
+// match. It lists every violation found.
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+// Error implements the error interface.
+func (e *SchemaError) Error() string {
+	lines := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		lines = append(lines, v.String())
+	}
+
+	return fmt.Sprintf("%d schema violation(s):\n\t%s", len(e.Violations), strings.Join(lines, "\n\t"))
+}
+
+// LoadSchema reads and compiles the JSON Schema stored at path.
+func LoadSchema(path string) (*Schema, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadSchema: %w", err)
+	}
+
+	return compileSchema(b)
+}
+
+// LoadSchemaFS reads and compiles the JSON Schema stored as name in fsys,
+// for example an embed.FS.
+func LoadSchemaFS(fsys fs.FS, name string) (*Schema, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("LoadSchemaFS: %w", err)
+	}
+
+	return compileSchema(b)
+}
+
+// NewSchema compiles a JSON Schema given as raw JSON (string, []byte or
+// json.RawMessage) or as any Go value that marshals to a schema, such as
+// gofight.D.
+func NewSchema(v any) (*Schema, error) {
+	var raw []byte
+	switch v := v.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	case json.RawMessage:
+		raw = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("NewSchema: %w", err)
+		}
+		raw = b
+	}
+
+	return compileSchema(raw)
+}
+
+// MustSchema is like NewSchema but panics if the schema cannot be compiled.
+// It simplifies declaring schemas as package-level variables in tests.
+func MustSchema(v any) *Schema {
+	s, err := NewSchema(v)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// compileSchema parses a schema document and indexes its anchors.
+func compileSchema(raw []byte) (*Schema, error) {
+	root, err := decodeJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+
+	switch root.(type) {
+	case bool, map[string]any:
+	default:
+		return nil, fmt.Errorf("invalid JSON schema: must be an object or a boolean, got %s", jsonType(root))
+	}
+
+	s := &Schema{
+		root:    root,
+		anchors: map[string]any{},
+		regexps: map[string]*regexp.Regexp{},
+	}
+	s.indexAnchors(root)
+
+	if err := s.checkSupported(root, "", true); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	if err := s.checkPatterns(root); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+
+	return s, nil
+}
+
+// indexAnchors records every $anchor and $dynamicAnchor in the document.
+func (s *Schema) indexAnchors(node any) {
+	switch v := node.(type) {
+	case map[string]any:
+		for _, key := range []string{"$anchor", "$dynamicAnchor"} {
+			if name, ok := v[key].(string); ok {
+				s.anchors[name] = v
+			}
+		}
+		for k, child := range v {
+			if k == "enum" || k == "const" {
+				continue
+			}
+			s.indexAnchors(child)
+		}
+	case []any:
+		for _, child := range v {
+			s.indexAnchors(child)
+		}
+	}
+}
+
+// Keywords whose value is a subschema, an array of subschemas or an object
+// of subschemas, walked by checkSupported.
+var (
+	schemaKeywords = []string{
+		"not", "if", "then", "else", "items", "contains",
+		"additionalProperties", "propertyNames",
+	}
+	schemaListKeywords = []string{"allOf", "anyOf", "oneOf", "prefixItems"}
+	schemaMapKeywords  = []string{"properties", "patternProperties", "dependentSchemas", "$defs", "definitions"}
+)
+
+// checkSupported rejects the keywords Schema does not implement and the
+// references it cannot resolve, so that they fail at load time instead of
+// being ignored during validation. ptr is the JSON pointer of node in the
+// schema document.
+func (s *Schema) checkSupported(node any, ptr string, root bool) error {
+	sc, ok := node.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	for _, key := range []string{"unevaluatedProperties", "unevaluatedItems"} {
+		if _, ok := sc[key]; ok {
+			return fmt.Errorf("%s at %q is not supported", key, ptr)
+		}
+	}
+	if _, ok := sc["$id"]; ok && !root {
+		return fmt.Errorf("embedded $id at %q is not supported", ptr)
+	}
+	for _, key := range []string{"$ref", "$dynamicRef"} {
+		if ref, ok := sc[key].(string); ok {
+			if _, err := s.resolve(ref); err != nil {
+				return fmt.Errorf("%s at %q: %w", key, ptr, err)
+			}
+		}
+	}
+
+	for _, key := range schemaKeywords {
+		if child, ok := sc[key]; ok {
+			if err := s.checkSupported(child, ptr+"/"+key, false); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range schemaListKeywords {
+		children, _ := sc[key].([]any)
+		for i, child := range children {
+			if err := s.checkSupported(child, ptr+"/"+key+"/"+strconv.Itoa(i), false); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range schemaMapKeywords {
+		children, _ := sc[key].(map[string]any)
+		for _, name := range slices.Sorted(maps.Keys(children)) {
+			if err := s.checkSupported(children[name], ptr+"/"+key+"/"+escapePointer(name), false); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkPatterns compiles every regular expression up front so that a typo
+// in the schema fails at load time rather than during validation.
+func (s *Schema) checkPatterns(node any) error {
+	switch v := node.(type) {
+	case map[string]any:
+		if p, ok := v["pattern"].(string); ok {
+			if _, err := s.regexp(p); err != nil {
+				return err
+			}
+		}
+		if props, ok := v["patternProperties"].(map[string]any); ok {
+			for p := range props {
+				if _, err := s.regexp(p); err != nil {
+					return err
+				}
+			}
+		}
+		for k, child := range v {
+			if k == "enum" || k == "const" {
+				continue
+			}
+			if err := s.checkPatterns(child); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if err := s.checkPatterns(child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// regexp returns the compiled form of pattern, caching the result.
+func (s *Schema) regexp(pattern string) (*regexp.Regexp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if re, ok := s.regexps[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	s.regexps[pattern] = re
+
+	return re, nil
+}
+
+// Validate checks the JSON document data against the schema. It returns a
+// *SchemaError listing every violation, or another error when data is not
+// valid JSON.
+func (s *Schema) Validate(data []byte) error {
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return fmt.Errorf("invalid JSON document: %w", err)
+	}
+
+	if violations := s.check(s.root, doc, "", 0); len(violations) > 0 {
+		return &SchemaError{Violations: violations}
+	}
+
+	return nil
+}
+
+// ValidateSchema validates the JSON response body against s, see
+// Schema.Validate.
+func (r HTTPResponse) ValidateSchema(s *Schema) error {
+	return s.Validate(r.Body.Bytes())
+}
+
+// MatchSchema expects the JSON response body to conform to s. Each
+// violation is reported separately, keyed by its JSON pointer.
+func (e *Expectation) MatchSchema(s *Schema) *Expectation {
+	err := e.resp.ValidateSchema(s)
+	if err == nil {
+		return e
+	}
+
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		return e.fail("expected body to match schema: %v", err)
+	}
+
+	for _, v := range schemaErr.Violations {
+		e.fail("schema violation at %s", v)
+	}
+
+	return e
+}
+
+// violation builds a SchemaViolation.
+func violation(ptr, keyword, format string, args ...any) SchemaViolation {
+	return SchemaViolation{Pointer: ptr, Keyword: keyword, Message: fmt.Sprintf(format, args...)}
+}
+
+// check validates inst against schema and returns every violation.
+func (s *Schema) check(schema, inst any, ptr string, depth int) []SchemaViolation {
+	if depth > maxSchemaDepth {
+		return []SchemaViolation{violation(ptr, "$ref", "schema recursion limit exceeded")}
+	}
+
+	switch sc := schema.(type) {
+	case bool:
+		if !sc {
+			return []SchemaViolation{violation(ptr, "false", "no value is allowed here")}
+		}
+		return nil
+	case map[string]any:
+		var out []SchemaViolation
+		out = append(out, s.checkRef(sc, inst, ptr, depth)...)
+		out = append(out, s.checkGeneric(sc, inst, ptr)...)
+		out = append(out, s.checkApplicators(sc, inst, ptr, depth)...)
+
+		switch v := inst.(type) {
+		case canonicalNumber:
+			out = append(out, checkNumber(sc, v, ptr)...)
+		case string:
+			out = append(out, s.checkString(sc, v, ptr)...)
+		case []any:
+			out = append(out, s.checkArray(sc, v, ptr, depth)...)
+		case map[string]any:
+			out = append(out, s.checkObject(sc, v, ptr, depth)...)
+		}
+
+		return out
+	default:
+		return []SchemaViolation{violation(ptr, "", "invalid schema of type %s", jsonType(schema))}
+	}
+}
+
+// valid reports whether inst matches schema.
+func (s *Schema) valid(schema, inst any, ptr string, depth int) bool {
+	return len(s.check(schema, inst, ptr, depth)) == 0
+}
+
+// checkRef follows $ref and $dynamicRef within the document.
+func (s *Schema) checkRef(sc map[string]any, inst any, ptr string, depth int) []SchemaViolation {
+	var out []SchemaViolation
+	for _, key := range []string{"$ref", "$dynamicRef"} {
+		ref, ok := sc[key].(string)
+		if !ok {
+			continue
+		}
+
+		target, err := s.resolve(ref)
+		if err != nil {
+			out = append(out, violation(ptr, key, "%v", err))
+			continue
+		}
+		out = append(out, s.check(target, inst, ptr, depth+1)...)
+	}
+
+	return out
+}
+
+// resolve looks up a same-document reference.
+func (s *Schema) resolve(ref string) (any, error) {
+	i := strings.IndexByte(ref, '#')
+	if i != 0 {
+		return nil, fmt.Errorf("unsupported reference %q: only same-document references are supported", ref)
+	}
+
+	fragment := ref[1:]
+	if fragment == "" {
+		return s.root, nil
+	}
+
+	if !strings.HasPrefix(fragment, "/") {
+		if target, ok := s.anchors[fragment]; ok {
+			return target, nil
+		}
+		return nil, fmt.Errorf("unknown anchor %q", fragment)
+	}
+
+	node := s.root
+	for _, token := range strings.Split(fragment[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := node.(type) {
+		case map[string]any:
+			child, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("unresolvable reference %q", ref)
+			}
+			node = child
+		case []any:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, fmt.Errorf("unresolvable reference %q", ref)
+			}
+			node = v[idx]
+		default:
+			return nil, fmt.Errorf("unresolvable reference %q", ref)
+		}
+	}
+
+	return node, nil
+}
+
+// checkGeneric validates type, enum and const.
+func (s *Schema) checkGeneric(sc map[string]any, inst any, ptr string) []SchemaViolation {
+	var out []SchemaViolation
+
+	if t, ok := sc["type"]; ok {
+		var types []string
+		switch t := t.(type) {
+		case string:
+			types = []string{t}
+		case []any:
+			for _, item := range t {
+				if name, ok := item.(string); ok {
+					types = append(types, name)
+				}
+			}
+		}
+
+		got := instanceType(inst)
+		matched := slices.Contains(types, got) || (got == "integer" && slices.Contains(types, JSONNumber))
+		if !matched {
+			out = append(out, violation(ptr, "type", "expected %s, got %s", strings.Join(types, " or "), got))
+		}
+	}
+
+	if enum, ok := sc["enum"].([]any); ok {
+		if !slices.ContainsFunc(enum, func(v any) bool { return reflect.DeepEqual(v, inst) }) {
+			out = append(out, violation(ptr, "enum", "value %s is not one of %s", marshalCompact(inst), marshalCompact(enum)))
+		}
+	}
+
+	if c, ok := sc["const"]; ok && !reflect.DeepEqual(c, inst) {
+		out = append(out, violation(ptr, "const", "expected %s, got %s", marshalCompact(c), marshalCompact(inst)))
+	}
+
+	return out
+}
+
+// checkApplicators validates the in-place applicators.
+func (s *Schema) checkApplicators(sc map[string]any, inst any, ptr string, depth int) []SchemaViolation {
+	var out []SchemaViolation
+
+	if all, ok := sc["allOf"].([]any); ok {
+		for _, sub := range all {
+			out = append(out, s.check(sub, inst, ptr, depth+1)...)
+		}
+	}
+
+	if anyOf, ok := sc["anyOf"].([]any); ok {
+		if !slices.ContainsFunc(anyOf, func(sub any) bool { return s.valid(sub, inst, ptr, depth+1) }) {
+			out = append(out, violation(ptr, "anyOf", "value does not match any of the %d schemas", len(anyOf)))
+		}
+	}
+
+	if oneOf, ok := sc["oneOf"].([]any); ok {
+		matches := 0
+		for _, sub := range oneOf {
+			if s.valid(sub, inst, ptr, depth+1) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			out = append(out, violation(ptr, "oneOf", "value matches %d of the %d schemas, expected exactly one", matches, len(oneOf)))
+		}
+	}
+
+	if not, ok := sc["not"]; ok && s.valid(not, inst, ptr, depth+1) {
+		out = append(out, violation(ptr, "not", "value must not match the schema"))
+	}
+
+	if cond, ok := sc["if"]; ok {
+		if s.valid(cond, inst, ptr, depth+1) {
+			if then, ok := sc["then"]; ok {
+				out = append(out, s.check(then, inst, ptr, depth+1)...)
+			}
+		} else if els, ok := sc["else"]; ok {
+			out = append(out, s.check(els, inst, ptr, depth+1)...)
+		}
+	}
+
+	return out
+}
+
+// checkNumber validates the numeric keywords.
+func checkNumber(sc map[string]any, n canonicalNumber, ptr string) []SchemaViolation {
+	var out []SchemaViolation
+	value, ok := numberRat(n)
+	if !ok {
+		return nil
+	}
+
+	bound := func(keyword string, fails func(c int) bool, message string) {
+		limit, ok := schemaRat(sc[keyword])
+		if ok && fails(value.Cmp(limit)) {
+			out = append(out, violation(ptr, keyword, "%s %s %s", n, message, ratString(limit)))
+		}
+	}
+	bound("minimum", func(c int) bool { return c < 0 }, "is less than")
+	bound("maximum", func(c int) bool { return c > 0 }, "is greater than")
+	bound("exclusiveMinimum", func(c int) bool { return c <= 0 }, "is not greater than")
+	bound("exclusiveMaximum", func(c int) bool { return c >= 0 }, "is not less than")
+
+	if div, ok := schemaRat(sc["multipleOf"]); ok && div.Sign() > 0 {
+		if !new(big.Rat).Quo(value, div).IsInt() {
+			out = append(out, violation(ptr, "multipleOf", "%s is not a multiple of %s", n, ratString(div)))
+		}
+	}
+
+	return out
+}
+
+// checkString validates the string keywords.
+func (s *Schema) checkString(sc map[string]any, str, ptr string) []SchemaViolation {
+	var out []SchemaViolation
+	length := utf8.RuneCountInString(str)
+
+	if limit, ok := schemaInt(sc["minLength"]); ok && length < limit {
+		out = append(out, violation(ptr, "minLength", "length %d is less than %d", length, limit))
+	}
+
+	if limit, ok := schemaInt(sc["maxLength"]); ok && length > limit {
+		out = append(out, violation(ptr, "maxLength", "length %d is greater than %d", length, limit))
+	}
+
+	if pattern, ok := sc["pattern"].(string); ok {
+		if re, err := s.regexp(pattern); err == nil && !re.MatchString(str) {
+			out = append(out, violation(ptr, "pattern", "%q does not match pattern %q", str, pattern))
+		}
+	}
+
+	return out
+}
+
+// checkArray validates the array keywords.
+func (s *Schema) checkArray(sc map[string]any, arr []any, ptr string, depth int) []SchemaViolation {
+	var out []SchemaViolation
+
+	if limit, ok := schemaInt(sc["minItems"]); ok && len(arr) < limit {
+		out = append(out, violation(ptr, "minItems", "array has %d items, expected at least %d", len(arr), limit))
+	}
+
+	if limit, ok := schemaInt(sc["maxItems"]); ok && len(arr) > limit {
+		out = append(out, violation(ptr, "maxItems", "array has %d items, expected at most %d", len(arr), limit))
+	}
+
+	if unique, _ := sc["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if reflect.DeepEqual(arr[i], arr[j]) {
+					out = append(out, violation(ptr, "uniqueItems", "items %d and %d are equal", i, j))
+				}
+			}
+		}
+	}
+
+	prefix, _ := sc["prefixItems"].([]any)
+	for i, sub := range prefix {
+		if i < len(arr) {
+			out = append(out, s.check(sub, arr[i], ptr+"/"+strconv.Itoa(i), depth+1)...)
+		}
+	}
+
+	if items, ok := sc["items"]; ok {
+		for i := len(prefix); i < len(arr); i++ {
+			out = append(out, s.check(items, arr[i], ptr+"/"+strconv.Itoa(i), depth+1)...)
+		}
+	}
+
+	if contains, ok := sc["contains"]; ok {
+		matches := 0
+		for i, item := range arr {
+			if s.valid(contains, item, ptr+"/"+strconv.Itoa(i), depth+1) {
+				matches++
+			}
+		}
+
+		minContains, hasMin := schemaInt(sc["minContains"])
+		if !hasMin {
+			minContains = 1
+		}
+		if matches < minContains {
+			out = append(out, violation(ptr, "contains", "array contains %d matching items, expected at least %d", matches, minContains))
+		}
+		if maxContains, ok := schemaInt(sc["maxContains"]); ok && matches > maxContains {
+			out = append(out, violation(ptr, "maxContains", "array contains %d matching items, expected at most %d", matches, maxContains))
+		}
+	}
+
+	return out
+}
+
+// checkObject validates the object keywords.
+func (s *Schema) checkObject(sc map[string]any, obj map[string]any, ptr string, depth int) []SchemaViolation {
+	var out []SchemaViolation
+	keys := slices.Sorted(maps.Keys(obj))
+
+	if limit, ok := schemaInt(sc["minProperties"]); ok && len(obj) < limit {
+		out = append(out, violation(ptr, "minProperties", "object has %d properties, expected at least %d", len(obj), limit))
+	}
+
+	if limit, ok := schemaInt(sc["maxProperties"]); ok && len(obj) > limit {
+		out = append(out, violation(ptr, "maxProperties", "object has %d properties, expected at most %d", len(obj), limit))
+	}
+
+	if required, ok := sc["required"].([]any); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, present := obj[name]; !present {
+					out = append(out, violation(ptr+"/"+escapePointer(name), "required", "required property %q is missing", name))
+				}
+			}
+		}
+	}
+
+	if deps, ok := sc["dependentRequired"].(map[string]any); ok {
+		for _, k := range keys {
+			list, ok := deps[k].([]any)
+			if !ok {
+				continue
+			}
+			for _, r := range list {
+				if name, ok := r.(string); ok {
+					if _, present := obj[name]; !present {
+						out = append(out, violation(ptr+"/"+escapePointer(name), "dependentRequired", "property %q is required by %q", name, k))
+					}
+				}
+			}
+		}
+	}
+
+	if deps, ok := sc["dependentSchemas"].(map[string]any); ok {
+		for _, k := range keys {
+			if sub, ok := deps[k]; ok {
+				out = append(out, s.check(sub, obj, ptr, depth+1)...)
+			}
+		}
+	}
+
+	props, _ := sc["properties"].(map[string]any)
+	patterns, _ := sc["patternProperties"].(map[string]any)
+	additional, hasAdditional := sc["additionalProperties"]
+	names, hasNames := sc["propertyNames"]
+
+	for _, k := range keys {
+		child := ptr + "/" + escapePointer(k)
+
+		if hasNames {
+			for _, v := range s.check(names, k, child, depth+1) {
+				v.Message = "property name: " + v.Message
+				out = append(out, v)
+			}
+		}
+
+		evaluated := false
+		if sub, ok := props[k]; ok {
+			evaluated = true
+			out = append(out, s.check(sub, obj[k], child, depth+1)...)
+		}
+
+		for p, sub := range patterns {
+			if re, err := s.regexp(p); err == nil && re.MatchString(k) {
+				evaluated = true
+				out = append(out, s.check(sub, obj[k], child, depth+1)...)
+			}
+		}
+
+		if !evaluated && hasAdditional {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				out = append(out, violation(child, "additionalProperties", "additional property %q is not allowed", k))
+				continue
+			}
+			out = append(out, s.check(additional, obj[k], child, depth+1)...)
+		}
+	}
+
+	return out
+}
+
+// instanceType returns the JSON Schema type name of a decoded value,
+// reporting integral numbers as "integer".
+func instanceType(v any) string {
+	if n, ok := v.(canonicalNumber); ok {
+		if r, ok := numberRat(n); ok && r.IsInt() {
+			return "integer"
+		}
+		return JSONNumber
+	}
+
+	return jsonType(v)
+}
+
+// numberRat parses a canonicalNumber.
+func numberRat(n canonicalNumber) (*big.Rat, bool) {
+	return new(big.Rat).SetString(string(n))
+}
+
+// schemaRat reads a numeric keyword value.
+func schemaRat(v any) (*big.Rat, bool) {
+	n, ok := v.(canonicalNumber)
+	if !ok {
+		return nil, false
+	}
+
+	return numberRat(n)
+}
+
+// schemaInt reads a non-negative integer keyword value.
+func schemaInt(v any) (int, bool) {
+	r, ok := schemaRat(v)
+	if !ok || !r.IsInt() || !r.Num().IsInt64() {
+		return 0, false
+	}
+
+	return int(r.Num().Int64()), true
+}
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// marshalCompact renders a normalized JSON value for messages.
+func marshalCompact(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,237 @@
+package gofight
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func userHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, body)
+	})
+}
+
+// schemaViolations validates data and returns the violations keyed by pointer
+func schemaViolations(t *testing.T, s *Schema, data string) map[string]string {
+	t.Helper()
+
+	err := s.Validate([]byte(data))
+	if err == nil {
+		return nil
+	}
+
+	var schemaErr *SchemaError
+	require.True(t, errors.As(err, &schemaErr), err.Error())
+
+	out := map[string]string{}
+	for _, v := range schemaErr.Violations {
+		out[v.Pointer] = v.Keyword
+	}
+
+	return out
+}
+
+// TestLoadSchema tests loading schemas from a file and an fs.FS
+func TestLoadSchema(t *testing.T) {
+	fromFile, err := LoadSchema("testdata/schema/user.json")
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile("testdata/schema/user.json")
+	require.NoError(t, err)
+	fromFS, err := LoadSchemaFS(fstest.MapFS{"user.json": {Data: raw}}, "user.json")
+	require.NoError(t, err)
+
+	valid := `{"id": 1, "name": "foo", "email": "foo@example.com", "roles": ["admin"], "address": {"city": "Taipei", "zip": null}}`
+	assert.NoError(t, fromFile.Validate([]byte(valid)))
+	assert.NoError(t, fromFS.Validate([]byte(valid)))
+
+	_, err = LoadSchema("testdata/schema/missing.json")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = LoadSchemaFS(fstest.MapFS{}, "missing.json")
+	assert.Error(t, err)
+}
+
+// TestSchemaViolations tests that every violation is reported by pointer
+func TestSchemaViolations(t *testing.T) {
+	s, err := LoadSchema("testdata/schema/user.json")
+	require.NoError(t, err)
+
+	got := schemaViolations(t, s, `{
+		"id": 0.5,
+		"name": "",
+		"email": "invalid",
+		"roles": ["admin", "root", "admin"],
+		"address": {"zip": 1},
+		"extra/key": true
+	}`)
+
+	assert.Equal(t, map[string]string{
+		"/id":           "minimum",
+		"/name":         "minLength",
+		"/email":        "pattern",
+		"/roles":        "uniqueItems",
+		"/roles/1":      "enum",
+		"/address/city": "required",
+		"/address/zip":  "type",
+		"/extra~1key":   "additionalProperties",
+	}, got)
+
+	err = s.Validate([]byte(`{"id": "1"}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/id: expected integer, got string")
+	assert.Contains(t, err.Error(), `/name: required property "name" is missing`)
+
+	assert.Error(t, s.Validate([]byte(`not json`)))
+}
+
+// TestNewSchema tests compiling schemas from Go values and raw JSON
+func TestNewSchema(t *testing.T) {
+	s, err := NewSchema(D{
+		"type":     "array",
+		"minItems": 1,
+		"prefixItems": []D{
+			{"const": "header"},
+		},
+		"items":       D{"type": "number", "multipleOf": 0.5, "exclusiveMaximum": 10},
+		"contains":    D{"type": "integer"},
+		"maxContains": 2,
+	})
+	require.NoError(t, err)
+
+	assert.Nil(t, schemaViolations(t, s, `["header", 1, 1.5]`))
+	assert.Equal(t, map[string]string{
+		"":   "maxContains",
+		"/0": "const",
+		"/1": "multipleOf",
+		"/2": "exclusiveMaximum",
+	}, schemaViolations(t, s, `["other", 1.25, 10, 2, 3]`))
+
+	_, err = NewSchema(`[]`)
+	assert.Error(t, err)
+	_, err = NewSchema(`{"pattern": "("}`)
+	assert.Error(t, err)
+	_, err = NewSchema(make(chan int))
+	assert.Error(t, err)
+	assert.Panics(t, func() { MustSchema(`{`) })
+}
+
+// TestSchemaApplicators tests the combinators and conditionals
+func TestSchemaApplicators(t *testing.T) {
+	s := MustSchema(`{
+		"$defs": {"positive": {"type": "integer", "exclusiveMinimum": 0}},
+		"type": "object",
+		"properties": {
+			"one": {"oneOf": [{"type": "integer"}, {"minimum": 5}]},
+			"any": {"anyOf": [{"type": "string"}, {"type": "boolean"}]},
+			"not": {"not": {"type": "null"}},
+			"all": {"allOf": [{"$ref": "#/$defs/positive"}, {"maximum": 3}]},
+			"kind": {"enum": ["a", "b"]}
+		},
+		"if": {"properties": {"kind": {"const": "a"}}},
+		"then": {"required": ["a"]},
+		"else": {"required": ["b"]},
+		"dependentRequired": {"one": ["any"]},
+		"propertyNames": {"maxLength": 4},
+		"patternProperties": {"^x-": {"type": "string"}},
+		"maxProperties": 7
+	}`)
+
+	assert.Nil(t, schemaViolations(t, s, `{"one": 5.5, "any": true, "not": 1, "all": 2, "kind": "a", "a": 1}`))
+	assert.Equal(t, map[string]string{
+		"/one":     "oneOf",
+		"/any":     "anyOf",
+		"/not":     "not",
+		"/all":     "maximum",
+		"/b":       "required",
+		"/x-id":    "type",
+		"/toolong": "maxLength",
+	}, schemaViolations(t, s, `{"one": 6, "any": 1, "not": null, "all": 4, "kind": "b", "x-id": 1, "toolong": 1}`))
+	// "if" holds vacuously when kind is absent, so "then" applies.
+	assert.Equal(t, map[string]string{
+		"/any": "dependentRequired",
+		"/a":   "required",
+	}, schemaViolations(t, s, `{"one": 1}`))
+
+	assert.Equal(t, map[string]string{"": "false"}, schemaViolations(t, MustSchema(`false`), `{}`))
+}
+
+// TestSchemaUnsupported tests that keywords the validator does not
+// implement fail to compile instead of being ignored
+func TestSchemaUnsupported(t *testing.T) {
+	for schema, want := range map[string]string{
+		`{"unevaluatedProperties": false}`:                     `unevaluatedProperties at "" is not supported`,
+		`{"allOf": [{"unevaluatedItems": false}]}`:             `unevaluatedItems at "/allOf/0" is not supported`,
+		`{"$ref": "other.json#"}`:                              `$ref at "": unsupported reference "other.json#"`,
+		`{"items": {"$ref": "https://example.com/item.json"}}`: `$ref at "/items": unsupported reference`,
+		`{"$defs": {"a/b": {"$id": "item.json"}}}`:             `embedded $id at "/$defs/a~1b" is not supported`,
+		`{"properties": {"a": {"$ref": "#/$defs/missing"}}}`:   `$ref at "/properties/a": unresolvable reference`,
+		`{"properties": {"a": {"$dynamicRef": "#missing"}}}`:   `$dynamicRef at "/properties/a": unknown anchor "missing"`,
+	} {
+		_, err := NewSchema(schema)
+		assert.ErrorContains(t, err, want, schema)
+	}
+
+	// A root $id and property names matching keywords are accepted.
+	s, err := NewSchema(`{
+		"$id": "https://example.com/user.json",
+		"properties": {"$id": {"type": "string"}, "unevaluatedItems": {}},
+		"examples": [{"$id": 1}]
+	}`)
+	require.NoError(t, err)
+	assert.Nil(t, schemaViolations(t, s, `{"$id": "x"}`))
+}
+
+// TestSchemaRecursiveRef tests recursive references to the root
+func TestSchemaRecursiveRef(t *testing.T) {
+	s := MustSchema(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"children": {"type": "array", "items": {"$ref": "#"}}
+		}
+	}`)
+
+	assert.Nil(t, schemaViolations(t, s, `{"name": "root", "children": [{"name": "a", "children": []}]}`))
+	assert.Equal(t, map[string]string{
+		"/children/0/children/0/name": "type",
+	}, schemaViolations(t, s, `{"children": [{"children": [{"name": 1}]}]}`))
+}
+
+// TestExpectMatchSchema tests the schema expectation on a response
+func TestExpectMatchSchema(t *testing.T) {
+	s, err := LoadSchema("testdata/schema/user.json")
+	require.NoError(t, err)
+
+	tb := &recordingTB{TB: t}
+	NewT(tb).GET("/").
+		Run(userHandler(`{"id": 1, "name": "foo", "email": "foo@example.com", "roles": []}`), func(r HTTPResponse, rq HTTPRequest) {
+			assert.NoError(t, r.ValidateSchema(s))
+			r.Expect().MatchSchema(s)
+		})
+	assert.Empty(t, tb.errors)
+
+	NewT(tb).GET("/").
+		Run(userHandler(`{"id": -1, "name": "foo", "email": "foo", "roles": []}`), func(r HTTPResponse, rq HTTPRequest) {
+			r.Expect().MatchSchema(s)
+		})
+	require.Len(t, tb.errors, 1)
+	assert.Contains(t, tb.errors[0], "2 expectation(s) failed")
+	assert.Contains(t, tb.errors[0], "schema violation at /id: -1 is less than 1")
+	assert.Contains(t, tb.errors[0], `schema violation at /email: "foo" does not match pattern`)
+
+	NewT(tb).GET("/").
+		Run(basicEngine(), func(r HTTPResponse, rq HTTPRequest) {
+			r.Expect().MatchSchema(s)
+		})
+	require.Len(t, tb.errors, 2)
+	assert.Contains(t, tb.errors[1], "expected body to match schema: invalid JSON document")
+}
@@ -0,0 +1,347 @@
+package gofight
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// Mode selects how a request reaches the handler.
+type Mode int
+
+const (
+	// ModeRecorder calls the handler directly with a Recorder. It is the
+	// default.
+	ModeRecorder Mode = iota
+	// ModeServer serves the handler with an httptest.Server and sends the
+	// request with an http.Client over a real HTTP/1.1 connection, so
+	// connection hijacking, server timeouts, Content-Length framing and
+	// RemoteAddr behave as in production.
+	ModeServer
+	// ModeTLS is like ModeServer over HTTPS. The server uses the bundled
+	// self-signed localhost certificate unless SetTLSCertificate is used,
+	// and the client trusts exactly that certificate. The server requests a
+	// client certificate, see SetClientCertificate.
+	ModeTLS
+	// ModeHTTP2 is like ModeTLS with HTTP/2 negotiated through ALPN.
+	ModeHTTP2
+	// ModeH2C serves HTTP/2 over cleartext, with prior knowledge and no
+	// HTTP/1.1 upgrade.
+	ModeH2C
+	// ModeRemote sends the request with an http.Client to the base URL
+	// given to NewRemote, such as a locally started binary. The handler
+	// passed to Run is ignored.
+	ModeRemote
+)
+
+// String returns the name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case ModeRecorder:
+		return "recorder"
+	case ModeServer:
+		return "server"
+	case ModeTLS:
+		return "tls"
+	case ModeHTTP2:
+		return "http2"
+	case ModeH2C:
+		return "h2c"
+	case ModeRemote:
+		return "remote"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// secure reports whether the mode uses TLS.
+func (m Mode) secure() bool {
+	return m == ModeTLS || m == ModeHTTP2
+}
+
+// SetMode selects how the request reaches the handler. In every mode but
+// ModeRecorder the HTTPResponse holds the response received by the client,
+// and the HTTPRequest is the request as received by the handler.
+func (rc *RequestConfig) SetMode(mode Mode) *RequestConfig {
+	rc.mode = mode
+
+	return rc
+}
+
+// ConfigureServer registers fn to customize the httptest.Server before it
+// starts, e.g. to set http.Server timeouts through its Config field. It
+// applies to every mode but ModeRecorder.
+func (rc *RequestConfig) ConfigureServer(fn func(*httptest.Server)) *RequestConfig {
+	rc.serverConfigs = append(rc.serverConfigs, fn)
+
+	return rc
+}
+
+// RunHTTP2 is like Run, with the handler served over HTTP/2 with TLS, see
+// ModeHTTP2.
+func (rc *RequestConfig) RunHTTP2(r http.Handler, response ResponseFunc) {
+	if rc.t != nil {
+		rc.t.Helper()
+	}
+
+	rc.runIn(ModeHTTP2, r, response)
+}
+
+// RunH2C is like Run, with the handler served over cleartext HTTP/2, see
+// ModeH2C.
+func (rc *RequestConfig) RunH2C(r http.Handler, response ResponseFunc) {
+	if rc.t != nil {
+		rc.t.Helper()
+	}
+
+	rc.runIn(ModeH2C, r, response)
+}
+
+// RunServer is like Run, with the handler served by an httptest.Server, see
+// ModeServer.
+func (rc *RequestConfig) RunServer(r http.Handler, response ResponseFunc) {
+	if rc.t != nil {
+		rc.t.Helper()
+	}
+
+	rc.runIn(ModeServer, r, response)
+}
+
+// runIn runs the request in mode, whatever mode was selected with SetMode
+// or NewRemote, and leaves the mode of the builder unchanged. It backs
+// RunServer, RunTLS, RunHTTP2 and RunH2C.
+func (rc *RequestConfig) runIn(mode Mode, r http.Handler, response ResponseFunc) {
+	if rc.t != nil {
+		rc.t.Helper()
+	}
+
+	defer func(prev Mode) { rc.mode = prev }(rc.mode)
+	rc.mode = mode
+	rc.Run(r, response)
+}
+
+// exchangeFunc sends a request and returns the recorded response together
+// with the request as received by the handler.
+type exchangeFunc func(req *http.Request) (*Recorder, *http.Request, error)
+
+// exchanger returns how requests reach h in the selected mode, and a
+// function releasing its resources.
+func (rc *RequestConfig) exchanger(h http.Handler) (exchangeFunc, func(), error) {
+	switch rc.mode {
+	case ModeRecorder:
+		return func(req *http.Request) (*Recorder, *http.Request, error) {
+			w := NewRecorder()
+			defer w.finish()
+			w.input = req.Body
+			h.ServeHTTP(w, req)
+			// Like a server, close the body once the handler returned, so
+			// that a streamed body stops producing.
+			if req.Body != nil {
+				_ = req.Body.Close()
+			}
+			return w, req, nil
+		}, func() {}, nil
+	case ModeServer, ModeTLS, ModeHTTP2, ModeH2C:
+		return rc.serverExchanger(h)
+	case ModeRemote:
+		return rc.remoteExchanger()
+	default:
+		return nil, nil, fmt.Errorf("unknown mode %s", rc.mode)
+	}
+}
+
+// serverExchanger starts an httptest.Server for h and returns a function
+// sending requests to it.
+func (rc *RequestConfig) serverExchanger(h http.Handler) (exchangeFunc, func(), error) {
+	received := make(chan *http.Request, 1)
+	srv, client, err := rc.startServer(rc.mode, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case received <- r:
+		default:
+		}
+		h.ServeHTTP(w, r)
+	}))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exchange := func(req *http.Request) (*Recorder, *http.Request, error) {
+		w, _, err := send(client, srv.URL, req)
+
+		served := req
+		select {
+		case served = <-received:
+		default:
+		}
+
+		return w, served, err
+	}
+
+	return exchange, srv.Close, nil
+}
+
+// startServer starts an httptest.Server for h configured for mode, and
+// returns it with a client trusting it. The client does not follow
+// redirects.
+func (rc *RequestConfig) startServer(mode Mode, h http.Handler) (*httptest.Server, *http.Client, error) {
+	srv := httptest.NewUnstartedServer(h)
+	switch mode {
+	case ModeTLS, ModeHTTP2:
+		cfg, err := rc.serverTLSConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+		srv.TLS = cfg
+		srv.EnableHTTP2 = mode == ModeHTTP2
+	case ModeH2C:
+		srv.Config.Protocols = new(http.Protocols)
+		srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	}
+	for _, fn := range rc.serverConfigs {
+		fn(srv)
+	}
+
+	var client *http.Client
+	if mode.secure() {
+		srv.StartTLS()
+		client = rc.tlsClient(srv)
+	} else {
+		srv.Start()
+		client = srv.Client()
+	}
+	if mode == ModeH2C {
+		transport := client.Transport.(*http.Transport).Clone()
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+		client.Transport = transport
+	}
+	// Like ModeRecorder, hand redirects to the ResponseFunc.
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return srv, client, nil
+}
+
+// send sends req to baseURL with client and records the response. It
+// returns the request sent by the client.
+func send(client *http.Client, baseURL string, req *http.Request) (*Recorder, *http.Request, error) {
+	out, err := clientRequest(baseURL, req)
+	if err != nil {
+		return NewRecorder(), req, err
+	}
+
+	resp, err := client.Do(out)
+	if err != nil {
+		return NewRecorder(), out, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	w, err := recordResponse(resp)
+
+	return w, out, err
+}
+
+// clientRequest returns the client request sending req to baseURL.
+func clientRequest(baseURL string, req *http.Request) (*http.Request, error) {
+	out, err := http.NewRequestWithContext(req.Context(), req.Method, strings.TrimSuffix(baseURL, "/")+req.URL.RequestURI(), req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client request: %w", err)
+	}
+	out.Header = req.Header.Clone()
+	out.ContentLength = req.ContentLength
+	out.TransferEncoding = req.TransferEncoding
+	out.GetBody = req.GetBody
+	if req.Host != "" {
+		out.Host = req.Host
+	}
+
+	return out, nil
+}
+
+// connMode returns the mode of a request that needs a real connection:
+// ModeServer in place of ModeRecorder. The mode of rc is left unchanged,
+// so that a later Run on the same builder still uses a Recorder.
+func (rc *RequestConfig) connMode() Mode {
+	if rc.mode == ModeRecorder {
+		return ModeServer
+	}
+
+	return rc.mode
+}
+
+// stream sends the request to h over a real connection, ModeRecorder
+// switching to ModeServer, and returns the response as soon as its header
+// is received, with the body left to read. stop cancels the request and
+// releases the server; it blocks until the handler returns.
+func (rc *RequestConfig) stream(r http.Handler) (*http.Response, func(), error) {
+	if err := rc.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	h, err := rc.handler(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := rc.newRequest()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		client  *http.Client
+		baseURL string
+		release func()
+	)
+	mode := rc.connMode()
+	switch mode {
+	case ModeServer, ModeTLS, ModeHTTP2, ModeH2C:
+		srv, c, err := rc.startServer(mode, h)
+		if err != nil {
+			return nil, nil, err
+		}
+		client, baseURL, release = c, srv.URL, srv.Close
+	case ModeRemote:
+		client, release = rc.remoteClient()
+		baseURL = rc.baseURL
+	default:
+		return nil, nil, fmt.Errorf("unknown mode %s", mode)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	req = req.WithContext(ctx)
+
+	secure := rc.isSecureContext() || mode.secure()
+	if rc.session != nil {
+		rc.session.prepare(req, secure)
+	}
+
+	out, err := clientRequest(baseURL, req)
+	if err != nil {
+		cancel()
+		release()
+		return nil, nil, err
+	}
+
+	resp, err := client.Do(out)
+	if err != nil {
+		cancel()
+		release()
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if rc.session != nil {
+		rc.session.store(req, resp.Cookies(), secure)
+	}
+
+	stop := func() {
+		cancel()
+		resp.Body.Close()
+		release()
+	}
+
+	return resp, stop, nil
+}
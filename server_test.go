@@ -0,0 +1,195 @@
+package gofight
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connInfoHandler describes the connection the request came from
+func connInfoHandler(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("X-Remote-Addr", r.RemoteAddr)
+	w.Header().Set("X-Proto", r.Proto)
+	_, _ = io.WriteString(w, r.Method+" "+r.URL.RequestURI()+" "+strconv.FormatInt(r.ContentLength, 10)+" "+string(body))
+}
+
+// TestRunServer tests requests sent over a real connection
+func TestRunServer(t *testing.T) {
+	New().POST("/echo?x=1").
+		SetBody("a=1").
+		SetHeader(H{"X-Test": "1"}).
+		RunServer(http.HandlerFunc(connInfoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+			assert.Equal(t, "POST /echo?x=1 3 a=1", r.Body.String())
+			assert.Equal(t, "HTTP/1.1", r.Header().Get("X-Proto"))
+			assert.Equal(t, "20", r.Header().Get("Content-Length"))
+
+			host, _, err := net.SplitHostPort(rq.RemoteAddr)
+			require.NoError(t, err)
+			assert.True(t, net.ParseIP(host).IsLoopback())
+			assert.Equal(t, "1", rq.Header.Get("X-Test"))
+			assert.Equal(t, ApplicationForm, rq.Header.Get(ContentType))
+		})
+}
+
+// TestRunServerHijack tests handlers that take over the connection
+func TestRunServerHijack(t *testing.T) {
+	hijack := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		defer conn.Close()
+		_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		_ = buf.Flush()
+	})
+
+	New().GET("/").
+		Run(hijack, func(r HTTPResponse, rq HTTPRequest) {
+			assert.True(t, r.Hijacked())
+			assert.Contains(t, string(r.HijackedBytes()), "\r\n\r\nhijacked")
+		})
+
+	New().GET("/").
+		SetMode(ModeServer).
+		Run(hijack, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+			assert.Equal(t, "hijacked", r.Body.String())
+		})
+}
+
+// TestRunServerTimeout tests server timeouts set with ConfigureServer
+func TestRunServerTimeout(t *testing.T) {
+	var readErr error
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	})
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		_, _ = pw.Write([]byte("partial"))
+	}()
+
+	New().POST("/").
+		SetBodyReader(bufio.NewReader(pr)).
+		SetMode(ModeServer).
+		ConfigureServer(func(s *httptest.Server) {
+			s.Config.ReadTimeout = 50 * time.Millisecond
+		}).
+		Run(slow, func(r HTTPResponse, rq HTTPRequest) {
+			var netErr net.Error
+			require.ErrorAs(t, readErr, &netErr)
+			assert.True(t, netErr.Timeout())
+		})
+}
+
+// TestRunServerRedirectAndSession tests redirects and cookies in server mode
+func TestRunServerRedirectAndSession(t *testing.T) {
+	New().GET("/").
+		SetMode(ModeServer).
+		Run(http.RedirectHandler("/next", http.StatusFound), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusFound, r.Code)
+			assert.Equal(t, "/next", r.Header().Get("Location"))
+		})
+
+	s := NewSession(sessionHandler())
+	s.New().POST("/login").SetForm(H{"user": "dave"}).SetMode(ModeServer).
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {})
+	s.New().GET("/me").SetMode(ModeServer).
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "session=dave;", r.Body.String())
+			assert.Contains(t, rq.Host, "127.0.0.1:")
+		})
+
+	New().POST("/private").
+		SetBody("payload").
+		SetDigestAuth("alice", "secret").
+		RunServer(http.HandlerFunc(digestHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "welcome alice payload", r.Body.String())
+		})
+}
+
+// TestModeString tests mode names
+func TestModeString(t *testing.T) {
+	assert.Equal(t, "server", ModeServer.String())
+	assert.Equal(t, "Mode(42)", Mode(42).String())
+	assert.ErrorContains(t, New().GET("/").SetMode(Mode(42)).RunE(basicEngine(), func(HTTPResponse, HTTPRequest) {}),
+		"unknown mode Mode(42)")
+}
+
+// protoHandler reports the protocol, echoes the body and sets a trailer
+func protoHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Trailer", "X-Checksum")
+	w.WriteHeader(http.StatusOK)
+	http.NewResponseController(w).Flush() //nolint:errcheck
+
+	body, _ := io.ReadAll(r.Body)
+	_, _ = io.WriteString(w, r.Proto+" "+string(body))
+	w.Header().Set("X-Checksum", strconv.Itoa(len(body)))
+}
+
+// TestRunHTTP2 tests HTTP/2 over TLS and cleartext
+func TestRunHTTP2(t *testing.T) {
+	New().POST("/").
+		SetBody("ping").
+		RunHTTP2(http.HandlerFunc(protoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "HTTP/2.0 ping", r.Body.String())
+			assert.Equal(t, "4", r.Result().Trailer.Get("X-Checksum"))
+			assert.Equal(t, 2, rq.ProtoMajor)
+			require.NotNil(t, rq.TLS)
+			assert.Equal(t, "h2", rq.TLS.NegotiatedProtocol)
+		})
+
+	New().POST("/").
+		SetBody("pong").
+		RunH2C(http.HandlerFunc(protoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "HTTP/2.0 pong", r.Body.String())
+			assert.Equal(t, "4", r.Result().Trailer.Get("X-Checksum"))
+			assert.Equal(t, 2, rq.ProtoMajor)
+			assert.Nil(t, rq.TLS)
+		})
+
+	// HTTP/1.1 is not full duplex: the body is gone once the response
+	// has started.
+	New().POST("/").
+		SetBody("ping").
+		SetMode(ModeTLS).
+		Run(http.HandlerFunc(protoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "HTTP/1.1 ", r.Body.String())
+			assert.Equal(t, "0", r.Result().Trailer.Get("X-Checksum"))
+		})
+}
+
+// TestRunModes tests that the Run helpers select their mode for one
+// request, whatever mode the builder has
+func TestRunModes(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Proto+" "+strconv.FormatBool(r.TLS != nil))
+	})
+
+	rc := NewT(t).GET("/").SetMode(ModeH2C)
+	rc.RunTLS(handler, func(r HTTPResponse, rq HTTPRequest) {
+		assert.Equal(t, "HTTP/1.1 true", r.Body.String())
+	})
+	rc.RunServer(handler, func(r HTTPResponse, rq HTTPRequest) {
+		assert.Equal(t, "HTTP/1.1 false", r.Body.String())
+	})
+	rc.RunHTTP2(handler, func(r HTTPResponse, rq HTTPRequest) {
+		assert.Equal(t, "HTTP/2.0 true", r.Body.String())
+	})
+	rc.Run(handler, func(r HTTPResponse, rq HTTPRequest) {
+		assert.Equal(t, "HTTP/2.0 false", r.Body.String())
+	})
+	assert.Equal(t, ModeH2C, rc.mode)
+}
@@ -0,0 +1,106 @@
+package gofight
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"testing"
+)
+
+// Session runs a series of requests against one handler, carrying cookies
+// from one response to the next like a browser would. Set-Cookie headers of
+// every response are stored in a cookie jar, and the cookies matching a
+// later request, by Domain, Path, expiry and Secure, are sent with it.
+//
+//	s := gofight.NewSession(handler)
+//	s.New().POST("/login").SetForm(gofight.H{"user": "a"}).Run(nil, ...)
+//	s.New().GET("/profile").Run(nil, ...) // sends the session cookie
+type Session struct {
+	handler http.Handler
+	jar     http.CookieJar
+	baseURL *url.URL
+}
+
+// NewSession creates a Session bound to handler, with an empty cookie jar
+// matching cookies as if requests were addressed to http://example.com.
+func NewSession(handler http.Handler) *Session {
+	// cookiejar.New only fails on invalid options.
+	jar, _ := cookiejar.New(nil)
+
+	return &Session{
+		handler: handler,
+		jar:     jar,
+		baseURL: &url.URL{Scheme: "http", Host: "example.com"},
+	}
+}
+
+// SetJar replaces the cookie jar, e.g. with one that is pre-populated or
+// uses a public suffix list.
+func (s *Session) SetJar(jar http.CookieJar) *Session {
+	s.jar = jar
+
+	return s
+}
+
+// SetBaseURL sets the scheme and host the jar matches cookies against: use
+// an https URL to replay Secure cookies, or another host to test Domain
+// attributes. The Host of the requests is left unchanged.
+func (s *Session) SetBaseURL(u *url.URL) *Session {
+	s.baseURL = &url.URL{Scheme: u.Scheme, Host: u.Host}
+
+	return s
+}
+
+// Jar returns the cookie jar of the session.
+func (s *Session) Jar() http.CookieJar {
+	return s.jar
+}
+
+// Cookies returns the cookies the session would send for path.
+func (s *Session) Cookies(path string) []*http.Cookie {
+	return s.jar.Cookies(s.baseURL.ResolveReference(&url.URL{Path: path}))
+}
+
+// New creates a request bound to the session. Run and RunE use the session
+// handler when called with a nil handler.
+func (s *Session) New() *RequestConfig {
+	rc := New()
+	rc.session = s
+
+	return rc
+}
+
+// NewT creates a request bound to the session and to t, see NewT.
+func (s *Session) NewT(t testing.TB) *RequestConfig {
+	rc := NewT(t)
+	rc.session = s
+
+	return rc
+}
+
+// cookieURL returns the URL the jar uses for req. Secure requests, made
+// with SetSecureContext or over TLS, use https.
+func (s *Session) cookieURL(req *http.Request, secure bool) *url.URL {
+	u := *s.baseURL
+	if secure {
+		u.Scheme = "https"
+	}
+	u.Path = req.URL.Path
+	u.RawQuery = req.URL.RawQuery
+
+	return &u
+}
+
+// prepare adds the cookies of the jar to req.
+func (s *Session) prepare(req *http.Request, secure bool) {
+	for _, c := range s.jar.Cookies(s.cookieURL(req, secure)) {
+		req.AddCookie(c)
+	}
+}
+
+// store saves the cookies set by the response in the jar.
+func (s *Session) store(req *http.Request, cookies []*http.Cookie, secure bool) {
+	if len(cookies) > 0 {
+		s.jar.SetCookies(s.cookieURL(req, secure), cookies)
+	}
+}
@@ -0,0 +1,116 @@
+package gofight
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionHandler implements a cookie based login flow
+func sessionHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: r.FormValue("user"), Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: "admin", Value: "1", Path: "/admin"})
+		http.SetCookie(w, &http.Cookie{Name: "secure", Value: "1", Path: "/", Secure: true})
+		http.SetCookie(w, &http.Cookie{Name: "expired", Value: "1", Path: "/", MaxAge: -1})
+	})
+	mux.HandleFunc("POST /logout", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+	})
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		for _, c := range r.Cookies() {
+			_, _ = io.WriteString(w, c.Name+"="+c.Value+";")
+		}
+	}
+	mux.HandleFunc("GET /me", echo)
+	mux.HandleFunc("GET /admin/me", echo)
+
+	return mux
+}
+
+// TestSession tests that cookies are carried across requests
+func TestSession(t *testing.T) {
+	s := NewSession(sessionHandler())
+
+	s.New().POST("/login").
+		SetForm(H{"user": "alice"}).
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+		})
+
+	s.NewT(t).GET("/me").
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "session=alice;", r.Body.String())
+			assert.Empty(t, rq.Host)
+		})
+
+	s.New().GET("/admin/me").
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "admin=1;session=alice;", r.Body.String())
+		})
+
+	s.New().POST("/logout").Run(nil, func(r HTTPResponse, rq HTTPRequest) {})
+
+	s.New().GET("/me").
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Empty(t, r.Body.String())
+		})
+	assert.Empty(t, s.Cookies("/me"))
+}
+
+// TestSessionSecure tests that Secure cookies are only sent over https
+func TestSessionSecure(t *testing.T) {
+	s := NewSession(sessionHandler()).
+		SetBaseURL(&url.URL{Scheme: "https", Host: "example.com"})
+
+	require.NoError(t, s.New().POST("/login").
+		SetForm(H{"user": "bob"}).
+		RunE(nil, func(r HTTPResponse, rq HTTPRequest) {}))
+
+	s.New().GET("/me").
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "session=bob;secure=1;", r.Body.String())
+		})
+}
+
+// TestSessionDigestAuth tests that the request answering a Digest challenge
+// carries the session cookies, including those set by the challenge
+func TestSessionDigestAuth(t *testing.T) {
+	s := NewSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Digest ") {
+			http.SetCookie(w, &http.Cookie{Name: "challenge", Value: "1", Path: "/"})
+		} else {
+			for _, c := range r.Cookies() {
+				_, _ = io.WriteString(w, c.Name+"="+c.Value+";")
+			}
+		}
+		digestHandler(w, r)
+	}))
+	s.Jar().SetCookies(&url.URL{Scheme: "http", Host: "example.com"}, []*http.Cookie{{Name: "session", Value: "alice", Path: "/"}})
+
+	s.NewT(t).GET("/private").
+		AddCookies(&http.Cookie{Name: "explicit", Value: "1"}).
+		SetDigestAuth("alice", "secret").
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusOK, r.Code)
+			assert.Equal(t, "explicit=1;session=alice;challenge=1;welcome alice ", r.Body.String())
+		})
+}
+
+// TestRunWithoutHandler tests the error returned when no handler is bound
+func TestRunWithoutHandler(t *testing.T) {
+	err := New().GET("/").RunE(nil, func(r HTTPResponse, rq HTTPRequest) {
+		t.Fatal("response func should not run")
+	})
+	assert.ErrorContains(t, err, "no handler")
+
+	New().GET("/").Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+		assert.Equal(t, http.StatusNotFound, r.Code)
+	})
+}
@@ -0,0 +1,273 @@
+package gofight
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// SnapshotDir is the directory, relative to the package under test, where
+// snapshots are stored.
+const SnapshotDir = "testdata/__snapshots__"
+
+// UpdateSnapshotsEnv is the environment variable that, when set to a true
+// value, rewrites snapshots instead of comparing them. A boolean -update
+// flag registered by the test binary has the same effect.
+const UpdateSnapshotsEnv = "GOFIGHT_UPDATE_SNAPSHOTS"
+
+// snapshotMask replaces masked values in snapshots.
+const snapshotMask = "<masked>"
+
+// snapshotConfig holds the options of MatchSnapshot.
+type snapshotConfig struct {
+	headers    []string
+	maskHeader map[string]bool
+	maskFields map[string]bool
+}
+
+// SnapshotOption customizes MatchSnapshot.
+type SnapshotOption func(*snapshotConfig)
+
+// SnapshotHeaders adds response headers to the snapshot. Only Content-Type
+// is recorded by default, so that volatile headers do not cause churn.
+func SnapshotHeaders(names ...string) SnapshotOption {
+	return func(c *snapshotConfig) {
+		for _, name := range names {
+			c.headers = append(c.headers, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// MaskHeaders records the headers in the snapshot with a placeholder value,
+// asserting they are present without depending on their value, e.g. Date.
+func MaskHeaders(names ...string) SnapshotOption {
+	return func(c *snapshotConfig) {
+		for _, name := range names {
+			key := http.CanonicalHeaderKey(name)
+			c.headers = append(c.headers, key)
+			c.maskHeader[key] = true
+		}
+	}
+}
+
+// MaskJSONFields replaces the value of every JSON object member with one of
+// the given names, at any depth, with a placeholder, e.g. "id" or
+// "created_at".
+func MaskJSONFields(names ...string) SnapshotOption {
+	return func(c *snapshotConfig) {
+		for _, name := range names {
+			c.maskFields[name] = true
+		}
+	}
+}
+
+// MatchSnapshot compares the response with the golden file name stored in
+// SnapshotDir. The snapshot holds the status line, the selected headers and
+// the body, pretty-printed with sorted keys when it is JSON.
+//
+// When the comparison fails the difference is reported with t.Errorf; run
+// the tests with the -update flag or with GOFIGHT_UPDATE_SNAPSHOTS=1 to
+// accept the new output. A missing snapshot is written but also fails the
+// test, so that a snapshot that was not committed does not pass in CI,
+// unless snapshots are being updated.
+func (r HTTPResponse) MatchSnapshot(t testing.TB, name string, opts ...SnapshotOption) {
+	t.Helper()
+
+	cfg := &snapshotConfig{
+		headers:    []string{ContentType},
+		maskHeader: map[string]bool{},
+		maskFields: map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	got := r.snapshot(cfg)
+	path := filepath.Join(SnapshotDir, snapshotFileName(name))
+
+	want, err := os.ReadFile(path)
+	missing := errors.Is(err, os.ErrNotExist)
+	switch {
+	case missing || (err == nil && updateSnapshots()):
+		if err := writeSnapshot(path, got); err != nil {
+			t.Errorf("gofight: snapshot %s: %v", name, err)
+			return
+		}
+		switch {
+		case missing && !updateSnapshots():
+			t.Errorf("gofight: snapshot %s did not exist, created %s: review and commit it, or rerun with -update or %s=1",
+				name, path, UpdateSnapshotsEnv)
+		case missing:
+			t.Logf("gofight: snapshot %s created", path)
+		default:
+			t.Logf("gofight: snapshot %s updated", path)
+		}
+	case err != nil:
+		t.Errorf("gofight: snapshot %s: %v", name, err)
+	case string(want) != got:
+		t.Errorf("gofight: snapshot %s does not match %s (-want +got):\n%s\nrerun with -update or %s=1 to accept the changes",
+			name, path, lineDiff(string(want), got), UpdateSnapshotsEnv)
+	}
+}
+
+// snapshot serializes the response.
+func (r HTTPResponse) snapshot(cfg *snapshotConfig) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%d %s\n", r.Code, http.StatusText(r.Code))
+
+	header := r.Result().Header
+	seen := map[string]bool{}
+	for _, key := range cfg.headers {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		for _, v := range header.Values(key) {
+			if cfg.maskHeader[key] {
+				v = snapshotMask
+			}
+			fmt.Fprintf(&buf, "%s: %s\n", key, v)
+		}
+	}
+
+	buf.WriteString("\n")
+	buf.WriteString(normalizeSnapshotBody(r.Body.Bytes(), cfg.maskFields))
+
+	return buf.String()
+}
+
+// normalizeSnapshotBody pretty-prints JSON bodies with sorted keys and the
+// configured fields masked. Other bodies are kept verbatim.
+func normalizeSnapshotBody(body []byte, mask map[string]bool) string {
+	v, err := decodeJSON(body)
+	if err != nil {
+		s := string(body)
+		if s != "" && !strings.HasSuffix(s, "\n") {
+			s += "\n"
+		}
+		return s
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(maskJSON(v, mask)); err != nil {
+		return string(body)
+	}
+
+	return buf.String()
+}
+
+// maskJSON replaces the values of masked members in v.
+func maskJSON(v any, mask map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if mask[k] {
+				v[k] = snapshotMask
+				continue
+			}
+			v[k] = maskJSON(item, mask)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = maskJSON(item, mask)
+		}
+	}
+
+	return v
+}
+
+// unsafeFileChars matches characters replaced in snapshot file names.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// snapshotFileName turns a snapshot name into a file name.
+func snapshotFileName(name string) string {
+	return unsafeFileChars.ReplaceAllString(name, "_") + ".snap"
+}
+
+// writeSnapshot stores a snapshot, creating SnapshotDir as needed.
+func writeSnapshot(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(content), 0o600)
+}
+
+// updateSnapshots reports whether snapshots should be rewritten.
+func updateSnapshots() bool {
+	if v, err := strconv.ParseBool(os.Getenv(UpdateSnapshotsEnv)); err == nil && v {
+		return true
+	}
+
+	if f := flag.Lookup("update"); f != nil {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			if v, ok := getter.Get().(bool); ok {
+				return v
+			}
+		}
+	}
+
+	return false
+}
+
+// lineDiff renders a minimal line diff between want and got.
+func lineDiff(want, got string) string {
+	a := strings.SplitAfter(want, "\n")
+	b := strings.SplitAfter(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	line := func(prefix, s string) {
+		if s == "" {
+			return
+		}
+		buf.WriteString(prefix)
+		buf.WriteString(strings.TrimSuffix(s, "\n"))
+		buf.WriteString("\n")
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			line("  ", a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			line("- ", a[i])
+			i++
+		default:
+			line("+ ", b[j])
+			j++
+		}
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
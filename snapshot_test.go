@@ -0,0 +1,117 @@
+package gofight
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func snapshotHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Date", r.URL.Query().Get("date"))
+	w.Header().Set("X-Request-Id", "42")
+	_, _ = io.WriteString(w, `{"z": 1, "a": {"id": `+r.URL.Query().Get("id")+`, "name": "foo"}, "list": [1.0, 2]}`)
+}
+
+// TestMatchSnapshot tests comparing a response with a stored snapshot
+func TestMatchSnapshot(t *testing.T) {
+	New().GET("/?id=7&date=today").
+		Run(http.HandlerFunc(snapshotHandler), func(r HTTPResponse, rq HTTPRequest) {
+			r.MatchSnapshot(t, "TestMatchSnapshot/user",
+				SnapshotHeaders("x-request-id"),
+				MaskHeaders("Date"),
+				MaskJSONFields("id"))
+		})
+}
+
+// TestMatchSnapshotLifecycle tests creating, comparing and updating snapshots
+func TestMatchSnapshotLifecycle(t *testing.T) {
+	t.Chdir(t.TempDir())
+	path := filepath.Join(SnapshotDir, "user_1.snap")
+
+	run := func(tb testing.TB, id string) {
+		New().GET("/?id="+id).
+			Run(http.HandlerFunc(snapshotHandler), func(r HTTPResponse, rq HTTPRequest) {
+				r.MatchSnapshot(tb, "user/1")
+			})
+	}
+
+	created := &recordingTB{TB: t}
+	run(created, "1")
+	require.Len(t, created.errors, 1)
+	assert.Contains(t, created.errors[0], "did not exist, created "+path)
+	assert.Empty(t, created.logs)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `200 OK
+Content-Type: application/json
+
+{
+  "a": {
+    "id": 1,
+    "name": "foo"
+  },
+  "list": [
+    1,
+    2
+  ],
+  "z": 1
+}
+`, string(content))
+
+	same := &recordingTB{TB: t}
+	run(same, "1")
+	assert.Empty(t, same.errors)
+	assert.Empty(t, same.logs)
+
+	changed := &recordingTB{TB: t}
+	run(changed, "2")
+	require.Len(t, changed.errors, 1)
+	assert.Contains(t, changed.errors[0], "-     \"id\": 1,\n+     \"id\": 2,")
+	assert.Contains(t, changed.errors[0], UpdateSnapshotsEnv)
+
+	t.Setenv(UpdateSnapshotsEnv, "1")
+	updated := &recordingTB{TB: t}
+	run(updated, "2")
+	assert.Empty(t, updated.errors)
+	require.Len(t, updated.logs, 1)
+	assert.Contains(t, updated.logs[0], "updated")
+
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"id": 2`)
+
+	require.NoError(t, os.Remove(path))
+	recreated := &recordingTB{TB: t}
+	run(recreated, "1")
+	assert.Empty(t, recreated.errors)
+	require.Len(t, recreated.logs, 1)
+	assert.Contains(t, recreated.logs[0], "created")
+}
+
+// TestMatchSnapshotPlainBody tests snapshots of non JSON bodies
+func TestMatchSnapshotPlainBody(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv(UpdateSnapshotsEnv, "1")
+
+	New().GET("/").
+		Run(basicEngine(), func(r HTTPResponse, rq HTTPRequest) {
+			r.MatchSnapshot(t, "hello")
+		})
+
+	content, err := os.ReadFile(filepath.Join(SnapshotDir, "hello.snap"))
+	require.NoError(t, err)
+	assert.Equal(t, "200 OK\nContent-Type: text/plain\n\nHello World\n", string(content))
+}
+
+// TestLineDiff tests the snapshot diff output
+func TestLineDiff(t *testing.T) {
+	assert.Equal(t, "  a\n- b\n+ x\n  c", lineDiff("a\nb\nc\n", "a\nx\nc\n"))
+	assert.Equal(t, "  a\n+ b", lineDiff("a\n", "a\nb\n"))
+}
@@ -0,0 +1,398 @@
+package gofight
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sseDefaultTimeout bounds the wait for every event.
+const sseDefaultTimeout = 5 * time.Second
+
+// sseDefaultEvent is the type of the events without an event field.
+const sseDefaultEvent = "message"
+
+// ErrEventStreamClosed is returned when reading an EventStream after Close.
+var ErrEventStreamClosed = errors.New("sse: stream closed")
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// ID is the last event ID: it is kept from the previous events until a
+	// later event sets another one.
+	ID string
+	// Event is the event type, "message" for events without an event
+	// field. ExpectEvents treats an empty type as "message".
+	Event string
+	// Data is the data of the event, the lines of multi-line data joined
+	// with "\n".
+	Data string
+	// Retry is the reconnection time sent with the event, zero if none.
+	Retry time.Duration
+}
+
+// String formats the event as sent on the wire, e.g. for handlers under
+// test or failure messages. The default "message" type is left implicit.
+func (e Event) String() string {
+	var b strings.Builder
+	if e.ID != "" {
+		b.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" && e.Event != sseDefaultEvent {
+		b.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	for line := range strings.SplitSeq(e.Data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// EventStreamError is returned by OpenEventStream when the handler does
+// not answer with a 200 OK text/event-stream response. It holds the
+// response the handler sent instead.
+type EventStreamError struct {
+	Reason     string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Error implements the error interface.
+func (e *EventStreamError) Error() string {
+	return "sse: " + e.Reason
+}
+
+// EventStream reads the events of a text/event-stream response while the
+// handler is still writing it. Every read fails after the timeout, five
+// seconds by default, so a handler that stops sending fails the test
+// instead of hanging it.
+type EventStream struct {
+	// Response is the response of the handler. Its body is read by the
+	// EventStream.
+	Response *http.Response
+
+	events  chan Event
+	quit    chan struct{}
+	done    chan struct{}
+	err     error
+	timeout time.Duration
+	stop    func()
+	t       testing.TB
+
+	closed    bool
+	closeOnce sync.Once
+}
+
+// OpenEventStream sends the request and returns the event stream of the
+// response as soon as the handler sends its header. An
+// httptest.ResponseRecorder only hands back the body once the handler
+// returns, so the handler is served by an httptest.Server: ModeRecorder
+// switches to ModeServer, and the other modes are kept.
+//
+//	stream, err := gofight.NewT(t).GET("/events").OpenEventStream(engine)
+//	require.NoError(t, err)
+//	defer stream.Close()
+//
+//	stream.ExpectEvents(gofight.Event{ID: "1", Event: "ready", Data: "{}"})
+//	ev, err := stream.WaitForEvent("done")
+//
+// The request accepts text/event-stream unless an Accept header is set.
+// Close cancels the request context and waits for the handler to return.
+func (rc *RequestConfig) OpenEventStream(r http.Handler) (*EventStream, error) {
+	if rc.t != nil {
+		rc.t.Helper()
+	}
+
+	// Only this request accepts the stream: restore the header changes of
+	// the builder once it is sent.
+	ops := rc.headerOps
+	defer func() { rc.headerOps = ops }()
+	rc.headerOps = append(slices.Clip(ops), func(h http.Header) {
+		if h.Get("Accept") == "" {
+			h.Set("Accept", "text/event-stream")
+		}
+	})
+
+	resp, stop, err := rc.stream(r)
+	if err != nil {
+		return nil, fmt.Errorf("OpenEventStream: %w", err)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get(ContentType))
+	var reason string
+	switch {
+	case resp.StatusCode != http.StatusOK:
+		reason = "unexpected status " + resp.Status
+	case mediaType != "text/event-stream":
+		reason = fmt.Sprintf("unexpected content type %q", resp.Header.Get(ContentType))
+	}
+	if reason != "" {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, wsMaxMessageSize))
+		stop()
+		return nil, &EventStreamError{
+			Reason:     reason,
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		}
+	}
+
+	s := &EventStream{
+		Response: resp,
+		events:   make(chan Event),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+		timeout:  sseDefaultTimeout,
+		stop:     stop,
+		t:        rc.t,
+	}
+	go s.read()
+
+	return s, nil
+}
+
+// read parses the body until it ends or the stream is closed.
+func (s *EventStream) read() {
+	defer close(s.done)
+
+	s.err = parseEventStream(s.Response.Body, func(ev Event) bool {
+		select {
+		case s.events <- ev:
+			return true
+		case <-s.quit:
+			return false
+		}
+	})
+}
+
+// SetTimeout sets the time allowed for every following read.
+func (s *EventStream) SetTimeout(d time.Duration) *EventStream {
+	s.timeout = d
+
+	return s
+}
+
+// Next returns the next event. It returns io.EOF once the handler ended
+// the stream, and an error wrapping os.ErrDeadlineExceeded when no event
+// arrives within the timeout.
+func (s *EventStream) Next() (Event, error) {
+	return s.next(time.Now().Add(s.timeout))
+}
+
+// next returns the next event received before deadline.
+func (s *EventStream) next(deadline time.Time) (Event, error) {
+	if s.closed {
+		return Event{}, ErrEventStreamClosed
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case ev := <-s.events:
+		return ev, nil
+	case <-s.done:
+		if errors.Is(s.err, io.EOF) {
+			return Event{}, io.EOF
+		}
+		return Event{}, fmt.Errorf("sse: failed to read the stream: %w", s.err)
+	case <-timer.C:
+		return Event{}, fmt.Errorf("sse: no event within %s: %w", s.timeout, os.ErrDeadlineExceeded)
+	}
+}
+
+// WaitFor returns the first event matching match, discarding the events
+// before it. The timeout applies to the whole wait.
+func (s *EventStream) WaitFor(match func(Event) bool) (Event, error) {
+	deadline := time.Now().Add(s.timeout)
+	for {
+		ev, err := s.next(deadline)
+		if err != nil {
+			return ev, err
+		}
+		if match(ev) {
+			return ev, nil
+		}
+	}
+}
+
+// WaitForEvent returns the first event of type eventType, see WaitFor.
+func (s *EventStream) WaitForEvent(eventType string) (Event, error) {
+	return s.WaitFor(func(ev Event) bool {
+		return ev.Event == eventType
+	})
+}
+
+// Close cancels the request context and releases the server, once the
+// handler returned. It is safe to call Close several times.
+func (s *EventStream) Close() error {
+	s.closeOnce.Do(func() {
+		s.closed = true
+		close(s.quit)
+		s.stop()
+		<-s.done
+	})
+
+	return nil
+}
+
+// fail reports a failed assertion, see failf.
+func (s *EventStream) fail(format string, args ...any) {
+	if s.t != nil {
+		s.t.Helper()
+	}
+
+	failf(s.t, "sse: "+format, args...)
+}
+
+// ExpectEvents expects the next events to equal want, in order. Failures
+// are reported to the test bound with NewT, or panic without one.
+func (s *EventStream) ExpectEvents(want ...Event) *EventStream {
+	if s.t != nil {
+		s.t.Helper()
+	}
+
+	for i, w := range want {
+		if w.Event == "" {
+			w.Event = sseDefaultEvent
+		}
+		ev, err := s.Next()
+		if err != nil {
+			s.fail("expected event #%d %q: %v", i+1, w, err)
+			return s
+		}
+		if ev != w {
+			s.fail("expected event #%d %q, got %q", i+1, w, ev)
+		}
+	}
+
+	return s
+}
+
+// ExpectData expects the data of the next events to equal data, in order,
+// see ExpectEvents.
+func (s *EventStream) ExpectData(data ...string) *EventStream {
+	if s.t != nil {
+		s.t.Helper()
+	}
+
+	for i, want := range data {
+		ev, err := s.Next()
+		if err != nil {
+			s.fail("expected event #%d with data %q: %v", i+1, want, err)
+			return s
+		}
+		if ev.Data != want {
+			s.fail("expected event #%d with data %q, got %q", i+1, want, ev.Data)
+		}
+	}
+
+	return s
+}
+
+// ExpectEnd expects the handler to end the stream without sending any
+// further event.
+func (s *EventStream) ExpectEnd() *EventStream {
+	if s.t != nil {
+		s.t.Helper()
+	}
+
+	ev, err := s.Next()
+	switch {
+	case err == nil:
+		s.fail("expected the end of the stream, got event %q", ev)
+	case !errors.Is(err, io.EOF):
+		s.fail("expected the end of the stream: %v", err)
+	}
+
+	return s
+}
+
+// parseEventStream parses a text/event-stream as specified by the HTML
+// Living Standard, calling emit for every event until it returns false.
+// It returns io.EOF at the end of the stream.
+func parseEventStream(r io.Reader, emit func(Event) bool) error {
+	br := bufio.NewReader(r)
+
+	var (
+		lastID string
+		ev     Event
+		data   strings.Builder
+		line   []byte
+		skipLF bool
+		first  = true
+	)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if skipLF {
+			skipLF = false
+			if b == '\n' {
+				continue
+			}
+		}
+		if b != '\n' && b != '\r' {
+			line = append(line, b)
+			continue
+		}
+		skipLF = b == '\r'
+
+		field := string(line)
+		line = line[:0]
+		if first {
+			field = strings.TrimPrefix(field, "\uFEFF")
+			first = false
+		}
+
+		if field == "" {
+			if data.Len() > 0 {
+				ev.ID = lastID
+				if ev.Event == "" {
+					ev.Event = sseDefaultEvent
+				}
+				ev.Data = strings.TrimSuffix(data.String(), "\n")
+				if !emit(ev) {
+					return nil
+				}
+			}
+			ev = Event{}
+			data.Reset()
+			continue
+		}
+
+		name, value, _ := strings.Cut(field, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch name {
+		case "event":
+			ev.Event = value
+		case "data":
+			data.WriteString(value + "\n")
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				lastID = value
+			}
+		case "retry":
+			if value != "" && strings.Trim(value, "0123456789") == "" {
+				if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+					ev.Retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package gofight
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseHandler streams the events it receives on next, flushing each one,
+// until next is closed or the client goes away, which is reported on done
+func sseHandler(next <-chan string, done chan<- error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ContentType, "text/event-stream")
+		w.Header().Set("X-Accept", r.Header.Get("Accept"))
+		w.WriteHeader(http.StatusOK)
+		http.NewResponseController(w).Flush() //nolint:errcheck
+
+		for {
+			select {
+			case ev, ok := <-next:
+				if !ok {
+					done <- nil
+					return
+				}
+				_, _ = io.WriteString(w, ev)
+				http.NewResponseController(w).Flush() //nolint:errcheck
+			case <-r.Context().Done():
+				done <- r.Context().Err()
+				return
+			}
+		}
+	}
+}
+
+// TestOpenEventStream tests events read while the handler is running
+func TestOpenEventStream(t *testing.T) {
+	next := make(chan string)
+	done := make(chan error, 1)
+
+	stream, err := NewT(t).GET("/events").OpenEventStream(sseHandler(next, done))
+	require.NoError(t, err)
+	defer stream.Close()
+	assert.Equal(t, "text/event-stream", stream.Response.Header.Get("X-Accept"))
+
+	next <- "id: 1\nevent: ready\ndata: {}\n\n"
+	stream.ExpectEvents(Event{ID: "1", Event: "ready", Data: "{}"})
+
+	next <- "data: first\ndata: second\n\n: keep-alive\n\nretry: 3000\ndata: x\n\n"
+	stream.ExpectEvents(
+		Event{ID: "1", Data: "first\nsecond"},
+		Event{ID: "1", Data: "x", Retry: 3 * time.Second},
+	)
+
+	next <- Event{ID: "2", Event: "tick", Data: "1"}.String()
+	next <- "event: tick\ndata: 2\n\nid: 3\nevent: done\ndata: bye\n\n"
+	ev, err := stream.WaitForEvent("done")
+	require.NoError(t, err)
+	assert.Equal(t, Event{ID: "3", Event: "done", Data: "bye"}, ev)
+
+	next <- "event: tick\ndata: 3\n\ndata: default\n\n"
+	ev, err = stream.WaitForEvent("message")
+	require.NoError(t, err)
+	assert.Equal(t, Event{ID: "3", Event: "message", Data: "default"}, ev)
+
+	require.NoError(t, stream.Close())
+	require.ErrorIs(t, <-done, context.Canceled, "the request context is canceled")
+}
+
+// TestEventStreamEnd tests the end of the stream and timeouts
+func TestEventStreamEnd(t *testing.T) {
+	next := make(chan string, 1)
+	done := make(chan error, 1)
+
+	stream, err := NewT(t).GET("/events").
+		SetHeader(H{"Accept": "text/event-stream, */*"}).
+		OpenEventStream(sseHandler(next, done))
+	require.NoError(t, err)
+	defer stream.Close()
+	assert.Equal(t, "text/event-stream, */*", stream.Response.Header.Get("X-Accept"))
+
+	start := time.Now()
+	_, err = stream.SetTimeout(50 * time.Millisecond).Next()
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, err = stream.WaitForEvent("never")
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	next <- "data: last\n\n"
+	close(next)
+	stream.SetTimeout(time.Second).ExpectData("last").ExpectEnd()
+	require.NoError(t, <-done)
+
+	_, err = stream.Next()
+	require.ErrorIs(t, err, io.EOF)
+	require.NoError(t, stream.Close())
+	_, err = stream.Next()
+	require.ErrorIs(t, err, ErrEventStreamClosed)
+}
+
+// TestOpenEventStreamRejected tests responses that are not event streams
+func TestOpenEventStreamRejected(t *testing.T) {
+	_, err := New().GET("/events").OpenEventStream(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+
+	var streamErr *EventStreamError
+	require.ErrorAs(t, err, &streamErr)
+	assert.Equal(t, http.StatusUnauthorized, streamErr.StatusCode)
+	assert.Equal(t, "unauthorized\n", string(streamErr.Body))
+	assert.EqualError(t, err, "sse: unexpected status 401 Unauthorized")
+
+	_, err = New().GET("/events").OpenEventStream(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "{}")
+	}))
+	require.ErrorAs(t, err, &streamErr)
+	assert.Contains(t, err.Error(), `unexpected content type "text/plain; charset=utf-8"`)
+}
+
+// TestEventStreamModes tests streams over every connection
+func TestEventStreamModes(t *testing.T) {
+	for _, mode := range []Mode{ModeServer, ModeTLS, ModeHTTP2, ModeH2C} {
+		t.Run(mode.String(), func(t *testing.T) {
+			next := make(chan string, 1)
+			done := make(chan error, 1)
+
+			stream, err := NewT(t).GET("/events").SetMode(mode).OpenEventStream(sseHandler(next, done))
+			require.NoError(t, err)
+			defer stream.Close()
+
+			next <- "data: " + mode.String() + "\n\n"
+			stream.ExpectData(mode.String())
+		})
+	}
+}
+
+// TestEventStreamExpectFailures tests failed assertions are reported
+// TestOpenEventStreamAccept tests that only the stream request accepts
+// text/event-stream
+func TestOpenEventStreamAccept(t *testing.T) {
+	next := make(chan string)
+	done := make(chan error, 1)
+
+	rc := NewT(t).GET("/events")
+	stream, err := rc.OpenEventStream(sseHandler(next, done))
+	require.NoError(t, err)
+	assert.Equal(t, "text/event-stream", stream.Response.Header.Get("X-Accept"))
+	require.NoError(t, stream.Close())
+
+	rc.Run(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("Accept"))
+	}), func(r HTTPResponse, rq HTTPRequest) {
+		assert.Empty(t, r.Body.String())
+	})
+}
+
+func TestEventStreamExpectFailures(t *testing.T) {
+	next := make(chan string, 1)
+	done := make(chan error, 1)
+
+	tb := &recordingTB{TB: t}
+	stream, err := NewT(tb).GET("/events").OpenEventStream(sseHandler(next, done))
+	require.NoError(t, err)
+	defer stream.Close()
+
+	next <- "event: a\ndata: 1\n\n"
+	stream.ExpectEvents(Event{Event: "b", Data: "1"})
+	next <- "data: 2\n\n"
+	stream.ExpectData("3")
+	next <- "data: 4\n\n"
+	stream.ExpectEnd()
+	stream.SetTimeout(10 * time.Millisecond).ExpectData("5")
+
+	require.Len(t, tb.errors, 4)
+	assert.Equal(t, `gofight: sse: expected event #1 "event: b\ndata: 1\n\n", got "event: a\ndata: 1\n\n"`, tb.errors[0])
+	assert.Equal(t, `gofight: sse: expected event #1 with data "3", got "2"`, tb.errors[1])
+	assert.Equal(t, `gofight: sse: expected the end of the stream, got event "data: 4\n\n"`, tb.errors[2])
+	assert.Contains(t, tb.errors[3], "no event within 10ms")
+}
+
+// TestParseEventStream tests the parsing rules of the specification
+func TestParseEventStream(t *testing.T) {
+	input := "\uFEFFdata: bom\r\n\r\n" +
+		"data:no space\rdata:  two spaces\r\r" +
+		"data\n\n" +
+		"id: 7\nevent: only-type\n\n" +
+		": comment\nunknown: field\nid: bad\x00id\ndata: keeps 7\n\n" +
+		"retry: 1x\nretry: -1\ndata: no retry\n\n" +
+		"id\ndata: reset id\n\n" +
+		"data: incomplete"
+
+	var events []Event
+	err := parseEventStream(strings.NewReader(input), func(ev Event) bool {
+		events = append(events, ev)
+		return true
+	})
+	require.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, []Event{
+		{Event: "message", Data: "bom"},
+		{Event: "message", Data: "no space\n two spaces"},
+		{Event: "message", Data: ""},
+		{ID: "7", Event: "message", Data: "keeps 7"},
+		{ID: "7", Event: "message", Data: "no retry"},
+		{Event: "message", Data: "reset id"},
+	}, events)
+
+	// emit stops the parsing.
+	calls := 0
+	err = parseEventStream(strings.NewReader("data: 1\n\ndata: 2\n\n"), func(Event) bool {
+		calls++
+		return false
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
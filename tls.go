@@ -0,0 +1,108 @@
+package gofight
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// SetTLSCertificate sets the certificate served in ModeTLS and ModeHTTP2,
+// instead of the bundled localhost certificate.
+func (rc *RequestConfig) SetTLSCertificate(cert tls.Certificate) *RequestConfig {
+	rc.tlsCert = &cert
+
+	return rc
+}
+
+// SetClientCertificate sets the certificate the client presents over TLS,
+// so handlers reading Request.TLS.PeerCertificates for mutual TLS can be
+// tested.
+func (rc *RequestConfig) SetClientCertificate(cert tls.Certificate) *RequestConfig {
+	rc.clientCerts = append(rc.clientCerts, cert)
+
+	return rc
+}
+
+// SetClientCAs makes the server verify client certificates against pool;
+// the client then only presents a certificate issued by one of them. A
+// client certificate is still not required. By default client certificates
+// are requested but not verified.
+func (rc *RequestConfig) SetClientCAs(pool *x509.CertPool) *RequestConfig {
+	rc.clientCAs = pool
+
+	return rc
+}
+
+// RunTLS is like Run, with the handler served over HTTPS, see ModeTLS.
+func (rc *RequestConfig) RunTLS(r http.Handler, response ResponseFunc) {
+	if rc.t != nil {
+		rc.t.Helper()
+	}
+
+	rc.runIn(ModeTLS, r, response)
+}
+
+//go:embed certificate/localhost.cert
+var localhostCert []byte
+
+//go:embed certificate/localhost.key
+var localhostKey []byte
+
+// loadLocalhostCertificate parses the bundled localhost certificate.
+var loadLocalhostCertificate = sync.OnceValues(func() (tls.Certificate, error) {
+	return tls.X509KeyPair(localhostCert, localhostKey)
+})
+
+// serverTLSConfig returns the TLS configuration of the server.
+func (rc *RequestConfig) serverTLSConfig() (*tls.Config, error) {
+	cert, err := loadLocalhostCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the localhost certificate: %w", err)
+	}
+	if rc.tlsCert != nil {
+		cert = *rc.tlsCert
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequestClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+	if rc.clientCAs != nil {
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		cfg.ClientCAs = rc.clientCAs
+	}
+
+	return cfg, nil
+}
+
+// tlsClient returns a client for srv that trusts exactly the certificate
+// it serves: the bundled certificate has neither an IP address SAN nor a
+// lasting validity period, so it cannot pass regular verification.
+func (rc *RequestConfig) tlsClient(srv *httptest.Server) *http.Client {
+	client := srv.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+
+	want := srv.Certificate().Raw
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: true, //nolint:gosec // the certificate is pinned below
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 || !bytes.Equal(cs.PeerCertificates[0].Raw, want) {
+				return errors.New("gofight: unexpected server certificate")
+			}
+			return nil
+		},
+		Certificates: rc.clientCerts,
+		NextProtos:   srv.TLS.NextProtos,
+		MinVersion:   tls.VersionTLS12,
+	}
+	client.Transport = transport
+
+	return client
+}
@@ -0,0 +1,118 @@
+package gofight
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCertificate issues a certificate for cn, self-signed when parent is nil
+func newTestCertificate(t *testing.T, cn string, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+
+	issuer, signer := tmpl, any(key)
+	if parent != nil {
+		issuer = parent.Leaf
+		signer = parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, signer)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// tlsInfoHandler describes the TLS connection of the request
+func tlsInfoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.TLS == nil {
+		http.Error(w, "plain text", http.StatusBadRequest)
+		return
+	}
+	if len(r.TLS.PeerCertificates) == 0 {
+		http.Error(w, "no client certificate", http.StatusUnauthorized)
+		return
+	}
+	_, _ = w.Write([]byte("hello " + r.TLS.PeerCertificates[0].Subject.CommonName))
+}
+
+// TestRunTLS tests requests served over HTTPS with the bundled certificate
+func TestRunTLS(t *testing.T) {
+	New().GET("/").
+		RunTLS(http.HandlerFunc(tlsInfoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusUnauthorized, r.Code)
+			require.NotNil(t, rq.TLS)
+			assert.True(t, rq.TLS.HandshakeComplete)
+			assert.Equal(t, "HTTP/1.1", rq.Proto)
+		})
+
+	s := NewSession(sessionHandler())
+	s.New().POST("/login").SetForm(H{"user": "erin"}).SetMode(ModeTLS).
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {})
+	s.New().GET("/me").SetMode(ModeTLS).
+		Run(nil, func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "session=erin;secure=1;", r.Body.String())
+		})
+}
+
+// TestRunTLSCertificates tests custom server certificates and mutual TLS
+func TestRunTLSCertificates(t *testing.T) {
+	ca := newTestCertificate(t, "test CA", nil)
+	client := newTestCertificate(t, "client-1", &ca)
+	server := newTestCertificate(t, "server", nil)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	New().GET("/").
+		SetMode(ModeTLS).
+		SetTLSCertificate(server).
+		SetClientCertificate(client).
+		SetClientCAs(pool).
+		Run(http.HandlerFunc(tlsInfoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "hello client-1", r.Body.String())
+			assert.Equal(t, "test CA", rq.TLS.PeerCertificates[0].Issuer.CommonName)
+		})
+
+	// The client only presents certificates issued by the CAs the server
+	// accepts.
+	rogue := newTestCertificate(t, "rogue", nil)
+	New().GET("/").
+		SetMode(ModeTLS).
+		SetClientCertificate(rogue).
+		SetClientCAs(pool).
+		Run(http.HandlerFunc(tlsInfoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, http.StatusUnauthorized, r.Code)
+		})
+
+	New().GET("/").
+		SetMode(ModeTLS).
+		SetClientCertificate(rogue).
+		Run(http.HandlerFunc(tlsInfoHandler), func(r HTTPResponse, rq HTTPRequest) {
+			assert.Equal(t, "hello rogue", r.Body.String())
+		})
+}
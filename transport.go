@@ -0,0 +1,88 @@
+package gofight
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+)
+
+// transportRemoteAddr is the client address seen by handlers behind a
+// Transport, the same as with httptest.NewRequest.
+const transportRemoteAddr = "192.0.2.1:1234"
+
+// handlerTransport serves client requests with a handler.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+// Transport returns an http.RoundTripper serving every request with handler
+// in-process, through a Recorder like Run, so generated API clients and
+// SDKs can be tested against the real handlers without sockets:
+//
+//	client := &http.Client{Transport: gofight.Transport(engine)}
+//	api := openapi.NewClient("http://api.test", client)
+//
+// The handler sees a server-side request: RequestURI and Host are set, the
+// URL only has a path and a query, and https URLs come with Request.TLS set.
+// Client features such as cookie jars, redirects and timeouts work as usual,
+// and so do http.ResponseController flushes and deadlines in the handler.
+func Transport(handler http.Handler) http.RoundTripper {
+	return &handlerTransport{handler: handler}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	in := incomingRequest(req)
+	w := NewRecorder()
+	w.input = in.Body
+	t.handler.ServeHTTP(w, in)
+	w.finish()
+
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	resp := w.Result()
+	resp.Request = req
+
+	return resp, nil
+}
+
+// incomingRequest converts a client request into the request a server
+// would receive.
+func incomingRequest(req *http.Request) *http.Request {
+	in := req.Clone(req.Context())
+	in.URL = &url.URL{
+		Path:     req.URL.Path,
+		RawPath:  req.URL.RawPath,
+		RawQuery: req.URL.RawQuery,
+	}
+	in.RequestURI = req.URL.RequestURI()
+	if in.Host == "" {
+		in.Host = req.URL.Host
+	}
+	in.RemoteAddr = transportRemoteAddr
+	in.Proto, in.ProtoMajor, in.ProtoMinor = "HTTP/1.1", 1, 1
+	if in.Body == nil {
+		in.Body = http.NoBody
+	}
+	in.GetBody = nil
+
+	if req.URL.Scheme == "https" {
+		in.TLS = &tls.ConnectionState{
+			Version:           tls.VersionTLS13,
+			HandshakeComplete: true,
+			ServerName:        req.URL.Hostname(),
+		}
+	}
+
+	return in
+}
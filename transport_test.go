@@ -0,0 +1,106 @@
+package gofight
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTransport tests http.Client requests served in-process
+func TestTransport(t *testing.T) {
+	client := &http.Client{Transport: Transport(http.HandlerFunc(connInfoHandler))}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://api.test/echo?x=1", strings.NewReader("a=1"))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "POST /echo?x=1 3 a=1", string(body))
+	assert.Equal(t, transportRemoteAddr, resp.Header.Get("X-Remote-Addr"))
+	assert.Equal(t, "HTTP/1.1", resp.Header.Get("X-Proto"))
+	assert.Same(t, req, resp.Request)
+}
+
+// TestTransportResponseController tests that handlers behind a Transport
+// can use an http.ResponseController
+func TestTransportResponseController(t *testing.T) {
+	client := &http.Client{Transport: Transport(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		for _, err := range []error{
+			rc.SetReadDeadline(time.Now().Add(time.Second)),
+			rc.SetWriteDeadline(time.Now().Add(time.Second)),
+			rc.EnableFullDuplex(),
+		} {
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		_, _ = io.WriteString(w, "a")
+		_ = rc.Flush()
+		_, _ = io.WriteString(w, "b")
+	}))}
+
+	resp, err := client.Get("http://api.test/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ab", string(body))
+}
+
+// TestTransportClientFeatures tests cookies, redirects, TLS and cancellation
+func TestTransportClientFeatures(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/", sessionHandler())
+	mux.Handle("/go", http.RedirectHandler("/me", http.StatusFound))
+	mux.HandleFunc("/tls", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Host+" "+r.TLS.ServerName)
+	})
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: Transport(mux), Jar: jar}
+
+	get := func(ctx context.Context, method, url string, body io.Reader) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, method, url, body)
+		require.NoError(t, err)
+		req.Header.Set(ContentType, ApplicationForm)
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	_, err = get(context.Background(), http.MethodPost, "https://api.test/login", strings.NewReader("user=frank"))
+	require.NoError(t, err)
+
+	body, err := get(context.Background(), http.MethodGet, "https://api.test/go", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "session=frank;secure=1;", body)
+
+	body, err = get(context.Background(), http.MethodGet, "https://api.test:8443/tls", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "api.test:8443 api.test", body)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = get(ctx, http.MethodGet, "https://api.test/me", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
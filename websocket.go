@@ -0,0 +1,830 @@
+package gofight
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // required by the WebSocket handshake
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// WSMessageType is the type of a WebSocket message, its RFC 6455 opcode.
+type WSMessageType int
+
+// WebSocket message types.
+const (
+	WSText   WSMessageType = 1
+	WSBinary WSMessageType = 2
+	WSClose  WSMessageType = 8
+	WSPing   WSMessageType = 9
+	WSPong   WSMessageType = 10
+)
+
+// String returns the name of the message type.
+func (t WSMessageType) String() string {
+	switch t {
+	case WSText:
+		return "text"
+	case WSBinary:
+		return "binary"
+	case WSClose:
+		return "close"
+	case WSPing:
+		return "ping"
+	case WSPong:
+		return "pong"
+	default:
+		return fmt.Sprintf("WSMessageType(%d)", int(t))
+	}
+}
+
+// WebSocket close codes, see RFC 6455 section 7.4.1.
+const (
+	WSCloseNormal          = 1000
+	WSCloseGoingAway       = 1001
+	WSCloseProtocolError   = 1002
+	WSCloseUnsupportedData = 1003
+	WSCloseNoStatus        = 1005
+	WSCloseInvalidPayload  = 1007
+	WSClosePolicyViolation = 1008
+	WSCloseMessageTooBig   = 1009
+	WSCloseInternalError   = 1011
+)
+
+const (
+	// wsGUID is appended to the key to compute Sec-WebSocket-Accept.
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// wsDefaultTimeout bounds the handshake and every read and write.
+	wsDefaultTimeout = 5 * time.Second
+	// wsMaxMessageSize is the largest message read.
+	wsMaxMessageSize = 32 << 20
+	// wsOpContinuation is the opcode of the continuation frames of a
+	// fragmented message.
+	wsOpContinuation = 0
+)
+
+// ErrWSClosed is returned when reading from or writing to a WebSocket
+// connection after the closing handshake.
+var ErrWSClosed = errors.New("websocket: connection closed")
+
+// WSMessage is a message received on a WebSocket connection.
+type WSMessage struct {
+	Type WSMessageType
+	// Data is the payload of the message. For a close message it is the
+	// reason, see CloseCode and CloseReason.
+	Data []byte
+	// CloseCode is the status code of a close message, or WSCloseNoStatus
+	// when the peer sent none.
+	CloseCode int
+	// CloseReason is the reason of a close message.
+	CloseReason string
+}
+
+// Text returns the payload as a string.
+func (m WSMessage) Text() string {
+	return string(m.Data)
+}
+
+// WSHandshakeError is returned by DialWS when the handler does not accept
+// the WebSocket upgrade. It holds the response the handler sent instead.
+type WSHandshakeError struct {
+	Reason     string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Error implements the error interface.
+func (e *WSHandshakeError) Error() string {
+	return "websocket handshake failed: " + e.Reason
+}
+
+// WS is a GET request to path for a WebSocket endpoint, see DialWS.
+//
+//	conn, err := gofight.NewT(t).WS("/chat").
+//	  SetHeader(gofight.H{"Sec-WebSocket-Protocol": "chat.v1"}).
+//	  DialWS(engine)
+//	require.NoError(t, err)
+//	defer conn.Close()
+//
+//	conn.WriteText("hello")
+//	conn.ExpectText("echo: hello")
+func (rc *RequestConfig) WS(path string) *RequestConfig {
+	return rc.setHTTPMethod("GET", path)
+}
+
+// DialWS performs the WebSocket opening handshake with the handler and
+// returns the connection. The connection hijacked from a Recorder cannot
+// receive frames once the handshake is done, so the handler is served by
+// an httptest.Server: ModeRecorder switches to
+// ModeServer, ModeTLS and ModeHTTP2 connect over TLS with HTTP/1.1, and
+// ModeRemote dials the base URL. ModeH2C is not supported.
+//
+// Headers, cookies and the session of the RequestConfig are sent with the
+// handshake. When the handler answers with anything but 101 Switching
+// Protocols, the error is a *WSHandshakeError holding the response.
+//
+// Close releases the connection and the server.
+func (rc *RequestConfig) DialWS(r http.Handler) (*WSConn, error) {
+	if rc.t != nil {
+		rc.t.Helper()
+	}
+
+	if err := rc.Err(); err != nil {
+		return nil, err
+	}
+
+	h, err := rc.handler(r)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := rc.newRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	addr, prefix, tlsConfig, stop, err := rc.wsEndpoint(h)
+	if err != nil {
+		return nil, fmt.Errorf("DialWS: %w", err)
+	}
+	if prefix != "" {
+		req.URL.Path = prefix + req.URL.Path
+		req.URL.RawPath = ""
+	}
+
+	secure := rc.isSecureContext() || tlsConfig != nil
+	if rc.session != nil {
+		rc.session.prepare(req, secure)
+	}
+	if req.Host == "" {
+		req.Host = addr
+	}
+
+	c, err := wsHandshake(req, addr, tlsConfig)
+	if err != nil {
+		stop()
+		return nil, fmt.Errorf("DialWS: %w", err)
+	}
+	c.stop = stop
+	c.t = rc.t
+
+	if rc.session != nil {
+		rc.session.store(req, c.Response.Cookies(), secure)
+	}
+
+	return c, nil
+}
+
+// wsEndpoint returns the address serving h in the selected mode, the path
+// prefix of the requests, the TLS configuration of the client if any, and
+// a function releasing the server.
+func (rc *RequestConfig) wsEndpoint(h http.Handler) (string, string, *tls.Config, func(), error) {
+	mode := rc.connMode()
+	switch mode {
+	case ModeServer, ModeTLS, ModeHTTP2:
+		srv, client, err := rc.startServer(mode, h)
+		if err != nil {
+			return "", "", nil, nil, err
+		}
+
+		var cfg *tls.Config
+		if mode.secure() {
+			cfg = client.Transport.(*http.Transport).TLSClientConfig.Clone()
+			// The upgrade only exists in HTTP/1.1.
+			cfg.NextProtos = nil
+		}
+
+		return srv.Listener.Addr().String(), "", cfg, srv.Close, nil
+	case ModeRemote:
+		u, err := url.Parse(rc.baseURL)
+		if err != nil {
+			return "", "", nil, nil, err
+		}
+
+		addr := u.Host
+		var cfg *tls.Config
+		if u.Scheme == "https" {
+			cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+			if rc.client != nil {
+				if t, ok := rc.client.Transport.(*http.Transport); ok && t.TLSClientConfig != nil {
+					cfg = t.TLSClientConfig.Clone()
+				}
+			}
+			if cfg.ServerName == "" {
+				cfg.ServerName = u.Hostname()
+			}
+			cfg.NextProtos = nil
+			if u.Port() == "" {
+				addr = net.JoinHostPort(u.Hostname(), "443")
+			}
+		} else if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+
+		return addr, strings.TrimSuffix(u.Path, "/"), cfg, func() {}, nil
+	default:
+		return "", "", nil, nil, fmt.Errorf("WebSocket is not supported in mode %s", mode)
+	}
+}
+
+// wsHandshake connects to addr and upgrades the connection with req.
+func wsHandshake(req *http.Request, addr string, tlsConfig *tls.Config) (*WSConn, error) {
+	ctx := req.Context()
+	dialer := &net.Dialer{Timeout: wsDefaultTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		tc := tls.Client(conn, tlsConfig)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	challenge := base64.StdEncoding.EncodeToString(key)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", challenge)
+
+	_ = conn.SetDeadline(time.Now().Add(wsDefaultTimeout))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send the handshake: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read the handshake response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, wsMaxMessageSize))
+		resp.Body.Close()
+		conn.Close()
+		return nil, &WSHandshakeError{
+			Reason:     "unexpected status " + resp.Status,
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		}
+	}
+
+	var reason string
+	switch {
+	case !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket"):
+		reason = fmt.Sprintf("unexpected Upgrade header %q", resp.Header.Get("Upgrade"))
+	case !headerContainsToken(resp.Header, "Connection", "upgrade"):
+		reason = fmt.Sprintf("unexpected Connection header %q", resp.Header.Values("Connection"))
+	case resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(challenge):
+		reason = fmt.Sprintf("invalid Sec-WebSocket-Accept %q", resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	if reason != "" {
+		conn.Close()
+		return nil, &WSHandshakeError{
+			Reason:     reason,
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+		}
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	return &WSConn{
+		Response: resp,
+		conn:     conn,
+		br:       br,
+		timeout:  wsDefaultTimeout,
+	}, nil
+}
+
+// wsAccept returns the Sec-WebSocket-Accept value answering key.
+func wsAccept(key string) string {
+	h := sha1.New() //nolint:gosec
+	h.Write([]byte(key + wsGUID))
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContainsToken reports whether the comma separated values of the
+// header key contain token, ignoring case.
+func headerContainsToken(header http.Header, key, token string) bool {
+	for _, v := range header.Values(key) {
+		for item := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// WSConn is the client side of a WebSocket connection opened by DialWS.
+// Every read and write fails after the timeout, five seconds by default,
+// so a handler that does not answer fails the test instead of hanging it.
+//
+// Pings received while reading are answered with a pong, and a close
+// message is answered with a close message before the connection is
+// released.
+type WSConn struct {
+	// Response is the 101 Switching Protocols response of the handler.
+	Response *http.Response
+
+	conn    net.Conn
+	br      *bufio.Reader
+	timeout time.Duration
+	stop    func()
+	t       testing.TB
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	// partial is the fragmented message being read.
+	partialType WSMessageType
+	partial     []byte
+
+	closed    bool
+	closeOnce sync.Once
+}
+
+// SetTimeout sets the time allowed for every following read and write.
+func (c *WSConn) SetTimeout(d time.Duration) *WSConn {
+	c.timeout = d
+
+	return c
+}
+
+// Subprotocol returns the subprotocol selected by the handler, if any.
+func (c *WSConn) Subprotocol() string {
+	return c.Response.Header.Get("Sec-WebSocket-Protocol")
+}
+
+// WriteText sends a text message.
+func (c *WSConn) WriteText(text string) error {
+	return c.writeFrame(WSText, []byte(text))
+}
+
+// WriteBinary sends a binary message.
+func (c *WSConn) WriteBinary(data []byte) error {
+	return c.writeFrame(WSBinary, data)
+}
+
+// WriteJSON sends v encoded as JSON in a text message.
+func (c *WSConn) WriteJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("websocket: failed to encode JSON: %w", err)
+	}
+
+	return c.writeFrame(WSText, b)
+}
+
+// Ping sends a ping with an optional payload of up to 125 bytes. The pong
+// is returned by ReadMessage.
+func (c *WSConn) Ping(data []byte) error {
+	return c.writeFrame(WSPing, data)
+}
+
+// writeFrame sends a single masked frame.
+func (c *WSConn) writeFrame(op WSMessageType, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrWSClosed
+	}
+	if op >= WSClose && len(payload) > 125 {
+		return fmt.Errorf("websocket: %s payload of %d bytes exceeds 125 bytes", op, len(payload))
+	}
+	if op == WSClose {
+		c.closeSent = true
+	}
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if err := writeWSFrame(c.conn, true, byte(op), payload, true); err != nil {
+		return fmt.Errorf("websocket: failed to write %s frame: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReadMessage returns the next message, control messages included.
+// Fragmented messages are reassembled.
+func (c *WSConn) ReadMessage() (WSMessage, error) {
+	if c.closed {
+		return WSMessage{}, ErrWSClosed
+	}
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	for {
+		fin, op, masked, payload, err := readWSFrame(c.br, wsMaxMessageSize)
+		if err != nil {
+			return WSMessage{}, fmt.Errorf("websocket: failed to read frame: %w", err)
+		}
+		if masked {
+			return WSMessage{}, c.protocolError("masked frame from the server")
+		}
+
+		switch msgType := WSMessageType(op); msgType {
+		case WSPing:
+			_ = c.writeFrame(WSPong, payload)
+			return WSMessage{Type: WSPing, Data: payload}, nil
+		case WSPong:
+			return WSMessage{Type: WSPong, Data: payload}, nil
+		case WSClose:
+			return c.readClose(payload)
+		case WSText, WSBinary:
+			if c.partialType != 0 {
+				return WSMessage{}, c.protocolError("new message inside a fragmented message")
+			}
+			if !fin {
+				c.partialType, c.partial = msgType, payload
+				continue
+			}
+			return c.message(msgType, payload)
+		case wsOpContinuation:
+			if c.partialType == 0 {
+				return WSMessage{}, c.protocolError("continuation frame without a message")
+			}
+			if len(c.partial)+len(payload) > wsMaxMessageSize {
+				return WSMessage{}, c.protocolError("message exceeds the size limit")
+			}
+			c.partial = append(c.partial, payload...)
+			if !fin {
+				continue
+			}
+			msgType, data := c.partialType, c.partial
+			c.partialType, c.partial = 0, nil
+			return c.message(msgType, data)
+		default:
+			return WSMessage{}, c.protocolError(fmt.Sprintf("unknown opcode %d", op))
+		}
+	}
+}
+
+// message validates a complete data message.
+func (c *WSConn) message(msgType WSMessageType, data []byte) (WSMessage, error) {
+	if msgType == WSText && !utf8.Valid(data) {
+		return WSMessage{}, c.protocolError("text message is not valid UTF-8")
+	}
+
+	return WSMessage{Type: msgType, Data: data}, nil
+}
+
+// readClose answers the close message of the peer and releases the
+// connection.
+func (c *WSConn) readClose(payload []byte) (WSMessage, error) {
+	msg := WSMessage{Type: WSClose, Data: payload, CloseCode: WSCloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		return WSMessage{}, c.protocolError("close frame with a 1-byte payload")
+	case len(payload) >= 2:
+		msg.CloseCode = int(binary.BigEndian.Uint16(payload))
+		msg.CloseReason = string(payload[2:])
+		msg.Data = payload[2:]
+	}
+
+	// Echo the status code, as required by RFC 6455 section 5.5.1.
+	var echo []byte
+	if len(payload) >= 2 {
+		echo = payload[:2]
+	}
+	_ = c.writeFrame(WSClose, echo)
+	c.release()
+
+	return msg, nil
+}
+
+// protocolError closes the connection with WSCloseProtocolError and
+// returns an error describing the violation.
+func (c *WSConn) protocolError(reason string) error {
+	_ = c.writeFrame(WSClose, binary.BigEndian.AppendUint16(nil, WSCloseProtocolError))
+	c.release()
+
+	return errors.New("websocket: protocol error: " + reason)
+}
+
+// ReadText returns the next data message as a string. Ping and pong
+// messages are skipped. A close message returns ErrWSClosed.
+func (c *WSConn) ReadText() (string, error) {
+	msg, err := c.readData()
+	if err != nil {
+		return "", err
+	}
+
+	return string(msg.Data), nil
+}
+
+// ReadJSON decodes the next data message into v. Ping and pong messages
+// are skipped. A close message returns ErrWSClosed.
+func (c *WSConn) ReadJSON(v any) error {
+	msg, err := c.readData()
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(msg.Data, v); err != nil {
+		return fmt.Errorf("websocket: failed to decode JSON %q: %w", msg.Data, err)
+	}
+
+	return nil
+}
+
+// readData returns the next text or binary message.
+func (c *WSConn) readData() (WSMessage, error) {
+	msg, err := c.nextMessage()
+	if err != nil {
+		return msg, err
+	}
+	if msg.Type == WSClose {
+		return msg, fmt.Errorf("%w with code %d %q", ErrWSClosed, msg.CloseCode, msg.CloseReason)
+	}
+
+	return msg, nil
+}
+
+// nextMessage returns the next message that is not a ping or a pong.
+func (c *WSConn) nextMessage() (WSMessage, error) {
+	for {
+		msg, err := c.ReadMessage()
+		if err != nil || (msg.Type != WSPing && msg.Type != WSPong) {
+			return msg, err
+		}
+	}
+}
+
+// CloseWith starts the closing handshake with code and reason, waits for
+// the close message of the handler, discarding the messages received
+// before it, and releases the connection and the server.
+func (c *WSConn) CloseWith(code int, reason string) error {
+	defer c.release()
+
+	if c.closed {
+		return nil
+	}
+
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code)) //nolint:gosec // close codes fit in 16 bits
+	payload = append(payload, reason...)
+	if err := c.writeFrame(WSClose, payload); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := c.ReadMessage()
+		if errors.Is(err, io.EOF) {
+			// The handler dropped the connection without answering.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Type == WSClose {
+			return nil
+		}
+	}
+}
+
+// Close performs the closing handshake with WSCloseNormal, see CloseWith.
+// It is safe to call Close after the handler closed the connection.
+func (c *WSConn) Close() error {
+	return c.CloseWith(WSCloseNormal, "")
+}
+
+// release closes the network connection and stops the server.
+func (c *WSConn) release() {
+	c.closeOnce.Do(func() {
+		c.closed = true
+		c.conn.Close()
+		if c.stop != nil {
+			c.stop()
+		}
+	})
+}
+
+// fail reports a failed assertion, see failf.
+func (c *WSConn) fail(format string, args ...any) {
+	if c.t != nil {
+		c.t.Helper()
+	}
+
+	failf(c.t, "websocket: "+format, args...)
+}
+
+// expectData reads the next data message for an assertion.
+func (c *WSConn) expectData(want WSMessageType) (WSMessage, bool) {
+	if c.t != nil {
+		c.t.Helper()
+	}
+
+	msg, err := c.readData()
+	if err != nil {
+		c.fail("expected %s message: %v", want, err)
+		return msg, false
+	}
+	if msg.Type != want {
+		c.fail("expected %s message, got %s message %q", want, msg.Type, msg.Data)
+		return msg, false
+	}
+
+	return msg, true
+}
+
+// ExpectText reads the next data message, skipping pings and pongs, and
+// expects it to be the text want. Failures are reported to the test bound
+// with NewT, or panic without one.
+func (c *WSConn) ExpectText(want string) *WSConn {
+	if c.t != nil {
+		c.t.Helper()
+	}
+
+	if msg, ok := c.expectData(WSText); ok && string(msg.Data) != want {
+		c.fail("expected text message %q, got %q", want, msg.Data)
+	}
+
+	return c
+}
+
+// ExpectBinary reads the next data message and expects it to be the binary
+// message want, see ExpectText.
+func (c *WSConn) ExpectBinary(want []byte) *WSConn {
+	if c.t != nil {
+		c.t.Helper()
+	}
+
+	if msg, ok := c.expectData(WSBinary); ok && !bytes.Equal(msg.Data, want) {
+		c.fail("expected binary message %x, got %x", want, msg.Data)
+	}
+
+	return c
+}
+
+// ExpectJSON reads the next data message and expects it to be JSON
+// semantically equal to want, as with Expectation.JSON.
+func (c *WSConn) ExpectJSON(want any) *WSConn {
+	if c.t != nil {
+		c.t.Helper()
+	}
+
+	raw, wantValue, err := decodeExpectedJSON(want)
+	if err != nil {
+		c.fail("invalid expected JSON: %v", err)
+		return c
+	}
+
+	msg, ok := c.expectData(WSText)
+	if !ok {
+		return c
+	}
+
+	gotValue, err := decodeJSON(msg.Data)
+	if err != nil {
+		c.fail("expected JSON message, got %q: %v", msg.Data, err)
+		return c
+	}
+	if !reflect.DeepEqual(wantValue, gotValue) {
+		c.fail("expected JSON message %s, got %s", bytes.TrimSpace(raw), bytes.TrimSpace(msg.Data))
+	}
+
+	return c
+}
+
+// ExpectClose reads the next message, skipping pings and pongs, and
+// expects the handler to close the connection with code.
+func (c *WSConn) ExpectClose(code int) *WSConn {
+	if c.t != nil {
+		c.t.Helper()
+	}
+
+	msg, err := c.nextMessage()
+	switch {
+	case err != nil:
+		c.fail("expected close message %d: %v", code, err)
+	case msg.Type != WSClose:
+		c.fail("expected close message %d, got %s message %q", code, msg.Type, msg.Data)
+	case msg.CloseCode != code:
+		c.fail("expected close code %d, got %d %q", code, msg.CloseCode, msg.CloseReason)
+	}
+
+	return c
+}
+
+// writeWSFrame writes a frame, masking the payload when mask is set as
+// required from clients.
+func writeWSFrame(w io.Writer, fin bool, op byte, payload []byte, mask bool) error {
+	header := make([]byte, 2, 14)
+	header[0] = op
+	if fin {
+		header[0] |= 0x80
+	}
+
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if mask {
+		header[1] |= 0x80
+		key := make([]byte, 4)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		header = append(header, key...)
+		masked := make([]byte, len(payload))
+		for i, b := range payload {
+			masked[i] = b ^ key[i%4]
+		}
+		payload = masked
+	}
+
+	_, err := w.Write(append(header, payload...))
+
+	return err
+}
+
+// readWSFrame reads a frame and unmasks its payload.
+func readWSFrame(r io.Reader, limit int) (fin bool, op byte, masked bool, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return
+	}
+	if header[0]&0x70 != 0 {
+		err = errors.New("reserved bits set")
+		return
+	}
+	fin, op, masked = header[0]&0x80 != 0, header[0]&0x0F, header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(r, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(r, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > uint64(limit) { //nolint:gosec // limit is positive
+		err = fmt.Errorf("frame of %d bytes exceeds the %d bytes limit", length, limit)
+		return
+	}
+	if op >= byte(WSClose) && (!fin || length > 125) {
+		err = errors.New("invalid control frame")
+		return
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(r, key[:]); err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+
+	return
+}
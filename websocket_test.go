@@ -0,0 +1,311 @@
+package gofight
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsHandler is a minimal WebSocket server echoing messages and acting on
+// text commands
+func wsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	if r.URL.Query().Get("token") == "bad" {
+		http.Error(w, "invalid token", http.StatusForbidden)
+		return
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n")
+	if protocol := r.Header.Get("Sec-WebSocket-Protocol"); protocol != "" {
+		_, _ = brw.WriteString("Sec-WebSocket-Protocol: " + protocol + "\r\n")
+	}
+	_, _ = brw.WriteString("X-Request: " + r.URL.RequestURI() + " " + r.Header.Get("Cookie") + "\r\n\r\n")
+	_ = brw.Flush()
+
+	send := func(fin bool, op WSMessageType, payload string) {
+		_ = writeWSFrame(conn, fin, byte(op), []byte(payload), false)
+	}
+
+	for {
+		_, op, masked, payload, err := readWSFrame(brw.Reader, 1<<20)
+		if err != nil {
+			return
+		}
+		if !masked {
+			send(true, WSClose, string(binary.BigEndian.AppendUint16(nil, WSCloseProtocolError)))
+			return
+		}
+
+		switch WSMessageType(op) {
+		case WSText:
+			switch string(payload) {
+			case "heartbeat":
+				send(true, WSPing, "hb")
+			case "fragment":
+				send(false, WSText, "frag")
+				send(true, WSPing, "")
+				send(false, wsOpContinuation, "men")
+				send(true, wsOpContinuation, "t")
+			case "json":
+				send(true, WSText, `{"b": 2, "a": 1.0}`)
+			case "bye":
+				send(true, WSClose, string(binary.BigEndian.AppendUint16(nil, 4000))+"bye")
+			default:
+				send(true, WSText, "echo: "+string(payload))
+			}
+		case WSBinary:
+			send(true, WSBinary, string(payload))
+		case WSPing:
+			send(true, WSPong, string(payload))
+		case WSPong:
+			if len(payload) > 0 {
+				send(true, WSText, "pong: "+string(payload))
+			}
+		case WSClose:
+			send(true, WSClose, string(payload))
+			return
+		}
+	}
+}
+
+// TestDialWS tests messages exchanged over a WebSocket connection
+func TestDialWS(t *testing.T) {
+	conn, err := NewT(t).WS("/chat?room=1").
+		SetHeader(H{"Sec-WebSocket-Protocol": "chat.v1"}).
+		SetCookie(H{"session": "abc"}).
+		DialWS(http.HandlerFunc(wsHandler))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, http.StatusSwitchingProtocols, conn.Response.StatusCode)
+	assert.Equal(t, "chat.v1", conn.Subprotocol())
+	assert.Equal(t, "/chat?room=1 session=abc", conn.Response.Header.Get("X-Request"))
+
+	require.NoError(t, conn.WriteText("hello"))
+	conn.ExpectText("echo: hello")
+
+	require.NoError(t, conn.WriteBinary([]byte{0, 1, 2}))
+	conn.ExpectBinary([]byte{0, 1, 2})
+
+	require.NoError(t, conn.WriteJSON(map[string]int{"a": 1}))
+	conn.ExpectText(`echo: {"a":1}`)
+	require.NoError(t, conn.WriteText("json"))
+	conn.ExpectJSON(map[string]int{"a": 1, "b": 2})
+
+	require.NoError(t, conn.Ping([]byte("p")))
+	msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, WSMessage{Type: WSPong, Data: []byte("p")}, msg)
+
+	// The ping of the server is answered while reading.
+	require.NoError(t, conn.WriteText("heartbeat"))
+	msg, err = conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, WSPing, msg.Type)
+	conn.ExpectText("pong: hb")
+
+	// Fragments are reassembled around the interleaved ping.
+	require.NoError(t, conn.WriteText("fragment"))
+	conn.ExpectText("fragment")
+
+	long := make([]byte, 70000)
+	require.NoError(t, conn.WriteBinary(long))
+	conn.ExpectBinary(long)
+
+	require.NoError(t, conn.Close())
+	_, err = conn.ReadMessage()
+	require.ErrorIs(t, err, ErrWSClosed)
+	assert.NoError(t, conn.Close())
+}
+
+// TestDialWSClosedByHandler tests the closing handshake started by the handler
+func TestDialWSClosedByHandler(t *testing.T) {
+	conn, err := NewT(t).WS("/chat").DialWS(http.HandlerFunc(wsHandler))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteText("bye"))
+	conn.ExpectClose(4000)
+
+	require.ErrorIs(t, conn.WriteText("again"), ErrWSClosed)
+	_, err = conn.ReadText()
+	require.ErrorIs(t, err, ErrWSClosed)
+	assert.NoError(t, conn.Close())
+
+	conn, err = NewT(t).WS("/chat").DialWS(http.HandlerFunc(wsHandler))
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteText("bye"))
+	_, err = conn.ReadText()
+	require.ErrorIs(t, err, ErrWSClosed)
+	assert.Contains(t, err.Error(), `code 4000 "bye"`)
+}
+
+// TestDialWSRejected tests a handler refusing the upgrade
+func TestDialWSRejected(t *testing.T) {
+	_, err := New().WS("/chat?token=bad").DialWS(http.HandlerFunc(wsHandler))
+
+	var handshakeErr *WSHandshakeError
+	require.ErrorAs(t, err, &handshakeErr)
+	assert.Equal(t, http.StatusForbidden, handshakeErr.StatusCode)
+	assert.Equal(t, "invalid token\n", string(handshakeErr.Body))
+	assert.Contains(t, err.Error(), "unexpected status 403 Forbidden")
+
+	// A handler answering as for plain HTTP.
+	_, err = New().WS("/").DialWS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}))
+	require.ErrorAs(t, err, &handshakeErr)
+	assert.Contains(t, err.Error(), "unexpected Upgrade header")
+
+	_, err = New().WS("/").SetMode(ModeH2C).DialWS(http.HandlerFunc(wsHandler))
+	assert.ErrorContains(t, err, "not supported in mode h2c")
+}
+
+// TestDialWSModes tests the handshake over every supported connection
+func TestDialWSModes(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(wsHandler))
+	defer remote.Close()
+
+	tests := []struct {
+		name string
+		rc   *RequestConfig
+	}{
+		{"server", New().SetMode(ModeServer)},
+		{"tls", New().SetMode(ModeTLS)},
+		{"http2", New().SetMode(ModeHTTP2)},
+		{"remote", NewRemote(remote.URL + "/api/")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := tt.rc.WS("/chat").DialWS(http.HandlerFunc(wsHandler))
+			require.NoError(t, err)
+			defer conn.Close()
+
+			require.NoError(t, conn.WriteText(tt.name))
+			text, err := conn.ReadText()
+			require.NoError(t, err)
+			assert.Equal(t, "echo: "+tt.name, text)
+		})
+	}
+
+	conn, err := NewRemote(remote.URL + "/api/").WS("/chat").DialWS(nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "/api/chat", conn.Response.Header.Get("X-Request"))
+}
+
+// TestDialWSSession tests the session cookies sent with the handshake
+func TestDialWSSession(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "alice", Path: "/"})
+	})
+	mux.HandleFunc("/chat", wsHandler)
+	s := NewSession(mux)
+
+	s.New().POST("/login").Run(nil, func(r HTTPResponse, rq HTTPRequest) {})
+
+	conn, err := s.NewT(t).WS("/chat").DialWS(nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "/chat session=alice", conn.Response.Header.Get("X-Request"))
+}
+
+// TestDialWSTimeout tests reads failing instead of hanging
+func TestDialWSTimeout(t *testing.T) {
+	conn, err := New().WS("/").DialWS(http.HandlerFunc(wsHandler))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	start := time.Now()
+	_, err = conn.SetTimeout(50 * time.Millisecond).ReadMessage()
+
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// TestWSExpectFailures tests failed assertions are reported
+func TestWSExpectFailures(t *testing.T) {
+	tb := &recordingTB{TB: t}
+	conn, err := NewT(tb).WS("/").DialWS(http.HandlerFunc(wsHandler))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteText("hello"))
+	conn.ExpectText("hi")
+	require.NoError(t, conn.WriteText("hello"))
+	conn.ExpectBinary([]byte("echo: hello"))
+	require.NoError(t, conn.WriteText("json"))
+	conn.ExpectJSON(`{"a": 2, "b": 2}`)
+	require.NoError(t, conn.WriteText("hello"))
+	conn.ExpectClose(WSCloseNormal)
+
+	require.Len(t, tb.errors, 4)
+	assert.Equal(t, `gofight: websocket: expected text message "hi", got "echo: hello"`, tb.errors[0])
+	assert.Equal(t, `gofight: websocket: expected binary message, got text message "echo: hello"`, tb.errors[1])
+	assert.Contains(t, tb.errors[2], `expected JSON message {"a": 2, "b": 2}, got {"b": 2, "a": 1.0}`)
+	assert.Contains(t, tb.errors[3], "expected close message 1000, got text message")
+
+	// Without a bound test, a failed assertion panics.
+	plain, err := New().WS("/").DialWS(http.HandlerFunc(wsHandler))
+	require.NoError(t, err)
+	defer plain.Close()
+	require.NoError(t, plain.WriteText("hello"))
+	assert.PanicsWithValue(t, `gofight: websocket: expected text message "hi", got "echo: hello"`, func() {
+		plain.ExpectText("hi")
+	})
+}
+
+// TestWSFrames tests the frame encoding
+func TestWSFrames(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 65535, 65536} {
+		payload := make([]byte, size)
+		for i := range payload {
+			payload[i] = byte(i)
+		}
+
+		pr, pw := net.Pipe()
+		go func() {
+			_ = writeWSFrame(pw, true, byte(WSBinary), payload, true)
+		}()
+		fin, op, masked, got, err := readWSFrame(bufio.NewReader(pr), 1<<20)
+		require.NoError(t, err)
+		assert.True(t, fin)
+		assert.Equal(t, byte(WSBinary), op)
+		assert.True(t, masked)
+		assert.Equal(t, payload, got, size)
+	}
+
+	// RFC 6455 section 5.7: a masked "Hello".
+	frame := []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58}
+	_, _, _, got, err := readWSFrame(bytes.NewReader(frame), 125)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", string(got))
+
+	_, _, _, _, err = readWSFrame(bytes.NewReader([]byte{0x82, 0x7e, 0x01, 0x00}), 125)
+	assert.ErrorContains(t, err, "exceeds")
+	_, _, _, _, err = readWSFrame(bytes.NewReader([]byte{0x09, 0x00}), 125)
+	assert.ErrorContains(t, err, "invalid control frame")
+
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", wsAccept("dGhlIHNhbXBsZSBub25jZQ=="))
+}